@@ -1,6 +1,8 @@
 package config
 
 import (
+	"time"
+
 	"github.com/kelseyhightower/envconfig"
 	log "github.com/sirupsen/logrus"
 )
@@ -9,6 +11,15 @@ type serverConfig struct {
 	Port      int `default:"8888"`
 	MaxWorker int `default:"2"`
 	MaxQueue  int `default:"5"`
+	// RuntimeMetrics exports go_* and process_* metrics of the exporter itself
+	RuntimeMetrics bool `default:"true" split_words:"true"`
+	// AdminToken enables /admin/ endpoints protected by bearer token
+	AdminToken string `split_words:"true"`
+	// AdminPort serves /admin/ endpoints on another port instead of Port.
+	// It enables them without AdminToken, so it should not be exposed.
+	AdminPort int `split_words:"true"`
+	// LivenessMaxIntervals fails liveness if no job has finished within this number of intervals
+	LivenessMaxIntervals int `default:"3" split_words:"true"`
 }
 
 type githubConfig struct {
@@ -20,6 +31,137 @@ type githubConfig struct {
 	// Interval we should set because of API rate limit
 	// ref: https://developer.github.com/v3/#rate-limiting
 	Interval float32 `default:"30"`
+	// RepoConcurrency is how many repositories a job fetches at the same time
+	RepoConcurrency int `default:"4" split_words:"true"`
+	// RateLimitReserve is the remaining rate limit below which requests wait until reset
+	RateLimitReserve int `default:"100" split_words:"true"`
+	// RetryMaxAttempts is how many times a GET request is sent on 502, 503, 504 and network errors
+	RetryMaxAttempts int `default:"4" split_words:"true"`
+	// RetryBaseDelay is the first backoff. It doubles for each retry with jitter.
+	RetryBaseDelay time.Duration `default:"500ms" split_words:"true"`
+	// RetryMaxDelay is the upper limit of backoff
+	RetryMaxDelay time.Duration `default:"30s" split_words:"true"`
+	// HTTPCache sends conditional requests with ETag and Last-Modified of the previous responses
+	HTTPCache bool `default:"true" split_words:"true"`
+	// CacheDir keeps the previous responses on disk. If empty, they are kept in memory.
+	CacheDir string `split_words:"true"`
+	// CacheMaxMB is the size limit of the previous responses.
+	// The least recently used ones are evicted over it.
+	CacheMaxMB int `default:"128" split_words:"true"`
+	// CacheTTL evicts the previous responses unused for it
+	CacheTTL time.Duration `default:"24h" split_words:"true"`
+	// EventsPolling polls organization events with ETag
+	// and fetches only repositories which had activity since the last poll.
+	EventsPolling bool `split_words:"true"`
+	// EventsFullSyncInterval (minute) fetches all repositories periodically in events polling
+	// because events are delayed from 30 seconds to 6 hours and some activities like deployments have no events.
+	EventsFullSyncInterval float32 `default:"360" split_words:"true"`
+	// WebhookSecret enables /webhook endpoint.
+	// It must be the same as the secret of organization or repository webhooks.
+	WebhookSecret string `split_words:"true"`
+}
+
+// doraConfig is used to calculate DORA metrics
+// (lead time for changes, deployment frequency and change failure rate).
+type doraConfig struct {
+	// Enabled should be set only if you need DORA metrics
+	// because it calls deployment, release and compare APIs for each repository.
+	Enabled bool `default:"false"`
+	// Environment is the deployment environment regarded as production
+	Environment string `default:"production"`
+	// Window is the trailing period (day) which deployments and merged pull requests are counted in
+	Window int `default:"30"`
+}
+
+// reviewConfig is used to calculate code review participation
+type reviewConfig struct {
+	// Window is the trailing period (day) which reviews and review comments are counted in.
+	// Reviews are fetched for each pull request updated in it. 0 disables them.
+	Window int `default:"14"`
+}
+
+// prSizeConfig is used to calculate pull request size
+type prSizeConfig struct {
+	// Enabled should be set only if you need pull request size metrics
+	// because it fetches details of each open and recently merged pull request,
+	// as list API does not return additions, deletions, changed files and commits.
+	Enabled bool `default:"false"`
+	// Window is the trailing period (day) which merged pull requests are fetched in
+	Window int `default:"14"`
+	// Buckets are upper bounds of changed lines (additions + deletions) of each size label.
+	// default means XS: <=10, S: <=100, M: <=500, L: <=1000, XL: >1000.
+	// At most 5 buckets are allowed, which give XXL over the last one.
+	Buckets []int `default:"10,100,500,1000"`
+}
+
+// staleConfig is used to classify open pull requests and issues by how long they are untouched
+type staleConfig struct {
+	// After is the default period (day) after which an untouched item is stale
+	After float32 `default:"7"`
+	// AbandonedAfter is the default period (day) after which an untouched item is abandoned
+	AbandonedAfter float32 `default:"30" split_words:"true"`
+	// Rules overrides the default periods for specific orgs, repositories or labels.
+	// e.g. "my-org=3:14,my-org/my-repo=1:7,label:wip=14:60"
+	Rules string
+}
+
+// remoteWriteConfig is used to push metrics to Prometheus remote write endpoint after each job
+// for environments where Prometheus cannot scrape the exporter.
+type remoteWriteConfig struct {
+	// URL enables push mode. e.g. https://<prometheus>/api/v1/write
+	URL string
+	// Username and Password are used for basic auth
+	Username string
+	Password string
+	// BearerToken is used instead of basic auth if it is set
+	BearerToken string `split_words:"true"`
+	// Timeout is of each push request
+	Timeout time.Duration `default:"30s"`
+	// RetryMaxAttempts is how many times a push is sent on 429, 5xx and network errors
+	RetryMaxAttempts int `default:"3" split_words:"true"`
+	// RetryBaseDelay is the first backoff. It doubles for each retry with jitter.
+	RetryBaseDelay time.Duration `default:"1s" split_words:"true"`
+	// RetryMaxDelay is the upper limit of backoff
+	RetryMaxDelay time.Duration `default:"30s" split_words:"true"`
+	// MaxSeriesPerRequest splits a push into requests not to exceed the request size limit of the endpoint
+	MaxSeriesPerRequest int `default:"2000" split_words:"true"`
+}
+
+// otelConfig selects OpenTelemetry exporters.
+// Names follow OpenTelemetry SDK environment variables, and OTLP exporters read
+// the others such as OTEL_EXPORTER_OTLP_ENDPOINT and OTEL_METRIC_EXPORT_INTERVAL by themselves.
+type otelConfig struct {
+	// MetricsExporter is "otlp" to push the same metrics as /metrics over OTLP/HTTP, or "none"
+	MetricsExporter string `default:"none" split_words:"true"`
+	// TracesExporter is "otlp" to export traces of jobs and GitHub API calls over OTLP/HTTP, or "none"
+	TracesExporter string `default:"none" split_words:"true"`
+}
+
+// privacyConfig controls label values which may expose private information
+type privacyConfig struct {
+	// Titles, Emails and Logins are "keep", "drop", "hash" or "truncate:<length>".
+	// Titles are of issues and pull requests, Emails are of organizations,
+	// and Logins are of assignees, reviewers and users.
+	Titles string `default:"keep"`
+	Emails string `default:"keep"`
+	Logins string `default:"keep"`
+	// HashKey is the HMAC key of hashed values.
+	// It should be set so that hashed logins cannot be guessed from the members of the org.
+	HashKey string `split_words:"true"`
+	// RedactRepos is a regular expression of repository names to redact.
+	// Redacted repositories have pseudonymous names, and no titles, labels, branches, workflows or URLs.
+	RedactRepos string `split_words:"true"`
+	// RedactPrivateRepos redacts all private repositories
+	RedactPrivateRepos bool `split_words:"true"`
+}
+
+// webConfig secures the HTTP server
+type webConfig struct {
+	// ConfigFile is the web config file of Prometheus exporter-toolkit for TLS and basic auth.
+	// It is reloaded when it or the certificates are modified.
+	ConfigFile string `split_words:"true"`
+	// BearerToken is accepted as well as basic auth on /metrics, /status and /api/v1/
+	BearerToken string `split_words:"true"`
 }
 
 var (
@@ -27,6 +169,22 @@ var (
 	ServerConfig serverConfig
 	// GitHubConfig
 	GitHubConfig githubConfig
+	// DORAConfig
+	DORAConfig doraConfig
+	// ReviewConfig
+	ReviewConfig reviewConfig
+	// PRSizeConfig
+	PRSizeConfig prSizeConfig
+	// StaleConfig
+	StaleConfig staleConfig
+	// RemoteWriteConfig
+	RemoteWriteConfig remoteWriteConfig
+	// OTelConfig
+	OTelConfig otelConfig
+	// WebConfig
+	WebConfig webConfig
+	// PrivacyConfig
+	PrivacyConfig privacyConfig
 )
 
 func init() {
@@ -34,7 +192,59 @@ func init() {
 		log.Fatalf("server config error: %+v", err)
 	}
 
+	if err := envconfig.Process("WEB", &WebConfig); err != nil {
+		log.Fatalf("web config error: %+v", err)
+	}
+
 	if err := envconfig.Process("GITHUB", &GitHubConfig); err != nil {
 		log.Fatalf("GitHub config error: %+v", err)
 	}
+
+	if GitHubConfig.RepoConcurrency < 1 {
+		log.Fatalf("GitHub config error: repo concurrency must be positive")
+	}
+
+	if err := envconfig.Process("DORA", &DORAConfig); err != nil {
+		log.Fatalf("DORA config error: %+v", err)
+	}
+
+	if err := envconfig.Process("REVIEW", &ReviewConfig); err != nil {
+		log.Fatalf("review config error: %+v", err)
+	}
+
+	if err := envconfig.Process("PR_SIZE", &PRSizeConfig); err != nil {
+		log.Fatalf("pull request size config error: %+v", err)
+	}
+	if len(PRSizeConfig.Buckets) > 5 {
+		log.Fatalf("pull request size config error: at most 5 buckets are allowed")
+	}
+	for i := 1; i < len(PRSizeConfig.Buckets); i++ {
+		if PRSizeConfig.Buckets[i] <= PRSizeConfig.Buckets[i-1] {
+			log.Fatalf("pull request size config error: buckets must be strictly ascending")
+		}
+	}
+
+	if err := envconfig.Process("STALE", &StaleConfig); err != nil {
+		log.Fatalf("stale config error: %+v", err)
+	}
+
+	if err := envconfig.Process("REMOTE_WRITE", &RemoteWriteConfig); err != nil {
+		log.Fatalf("remote write config error: %+v", err)
+	}
+	if RemoteWriteConfig.MaxSeriesPerRequest < 1 {
+		log.Fatalf("remote write config error: max series per request must be positive")
+	}
+
+	if err := envconfig.Process("PRIVACY", &PrivacyConfig); err != nil {
+		log.Fatalf("privacy config error: %+v", err)
+	}
+
+	if err := envconfig.Process("OTEL", &OTelConfig); err != nil {
+		log.Fatalf("OpenTelemetry config error: %+v", err)
+	}
+	for _, exporter := range []string{OTelConfig.MetricsExporter, OTelConfig.TracesExporter} {
+		if exporter != "otlp" && exporter != "none" {
+			log.Fatalf("OpenTelemetry config error: unsupported exporter %s", exporter)
+		}
+	}
 }
@@ -0,0 +1,119 @@
+package exporter
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v28/github"
+	"github.com/patrickmn/go-cache"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/ko-da-k/github-developer-exporter/config"
+)
+
+const (
+	// events API returns at most 300 events
+	maxEventsPage = 3
+)
+
+var (
+	// repoCacheSuffixes are keys of cached data of each repository
+	repoCacheSuffixes = []string{
+		"pulls",
+		"issues",
+		"pull-details",
+		"reviews",
+		"review-comments",
+		"deployments",
+		"deliveries",
+	}
+)
+
+// changedRepos polls organization events with If-None-Match
+// and returns repository names which had activity since the last poll.
+// 304 Not Modified does not count against the rate limit.
+// It returns nil, which means all repositories, if full sync is needed.
+func (j *Job) changedRepos(ctx context.Context) (map[string]bool, error) {
+	req, err := j.client.NewRequest("GET", fmt.Sprintf("orgs/%v/events?per_page=100", j.orgName), nil)
+	if err != nil {
+		return nil, err
+	}
+	if j.eventsETag != "" {
+		req.Header.Set("If-None-Match", j.eventsETag)
+	}
+	var events []*github.Event
+	resp, err := j.client.Do(ctx, req, &events)
+	notModified := resp != nil && resp.StatusCode == http.StatusNotModified
+	if _, ok := err.(*github.RateLimitError); ok {
+		return nil, fmt.Errorf("Access Rate Limit: %w", err)
+	} else if err != nil && !notModified {
+		return nil, err
+	}
+
+	fullSync := j.lastEventID == "" ||
+		time.Since(j.lastFullSync) >= time.Duration(config.GitHubConfig.EventsFullSyncInterval)*time.Minute
+	if notModified && !fullSync {
+		log.Infof("%s has no new events", j.orgName)
+		return map[string]bool{}, nil
+	}
+	if !notModified {
+		j.eventsETag = resp.Header.Get("ETag")
+	}
+
+	changed := make(map[string]bool)
+	found := notModified
+	newest := j.lastEventID
+	if len(events) > 0 {
+		newest = events[0].GetID()
+	}
+	for page := 1; !notModified && page <= maxEventsPage; page++ {
+		if page > 1 {
+			events, resp, err = j.client.Activity.ListEventsForOrganization(ctx, j.orgName, &github.ListOptions{Page: page, PerPage: 100})
+			if err != nil {
+				return nil, err
+			}
+		}
+		for _, e := range events {
+			if e.GetID() == j.lastEventID {
+				found = true
+				break
+			}
+			// repo name is "<owner>/<repo>"
+			name := e.GetRepo().GetName()
+			changed[name[strings.Index(name, "/")+1:]] = true
+		}
+		if found || resp.NextPage == 0 {
+			break
+		}
+	}
+	j.lastEventID = newest
+
+	// events older than the last one may be dropped if the last one is not found
+	if fullSync || !found {
+		j.lastFullSync = time.Now()
+		log.Infof("%s full sync", j.orgName)
+		return nil, nil
+	}
+	log.Infof("%s has %d changed repositories", j.orgName, len(changed))
+	return changed, nil
+}
+
+// touchRepoCache extends the expiration of cached data of the repository.
+// It returns false if pulls or issues are not cached.
+func touchRepoCache(orgName, repoName string) bool {
+	for _, suffix := range repoCacheSuffixes {
+		key := fmt.Sprintf("%s-%s-%s", orgName, repoName, suffix)
+		v, found := Kv.Get(key)
+		if !found {
+			if suffix == "pulls" || suffix == "issues" {
+				return false
+			}
+			continue
+		}
+		Kv.Set(key, v, cache.DefaultExpiration)
+	}
+	return true
+}
@@ -0,0 +1,69 @@
+package exporter
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-github/v28/github"
+)
+
+// newTestClient returns github client which talks to the fake server
+func newTestClient(t *testing.T, handler http.Handler) (*github.Client, func()) {
+	server := httptest.NewServer(handler)
+	client := github.NewClient(nil)
+	u, err := url.Parse(server.URL + "/")
+	if err != nil {
+		t.Fatalf("%+v\n", err)
+	}
+	client.BaseURL = u
+	return client, server.Close
+}
+
+func TestChangedRepos(t *testing.T) {
+	events := `[{"id":"3","repo":{"name":"ko-da-k/hoge"}},{"id":"2","repo":{"name":"ko-da-k/fuga"}},{"id":"1","repo":{"name":"ko-da-k/piyo"}}]`
+	mux := http.NewServeMux()
+	mux.HandleFunc("/orgs/ko-da-k/events", func(w http.ResponseWriter, r *http.Request) {
+		etag := fmt.Sprintf(`"%d"`, len(events))
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		fmt.Fprint(w, events)
+	})
+	client, teardown := newTestClient(t, mux)
+	defer teardown()
+	j := NewJob(client, "ko-da-k")
+
+	// first poll is full sync
+	changed, err := j.changedRepos(context.Background())
+	if err != nil {
+		t.Fatalf("%+v\n", err)
+	}
+	if changed != nil {
+		t.Errorf("first poll should be full sync: got %v", changed)
+	}
+
+	// not modified
+	changed, err = j.changedRepos(context.Background())
+	if err != nil {
+		t.Fatalf("%+v\n", err)
+	}
+	if changed == nil || len(changed) != 0 {
+		t.Errorf("unexpected changed repositories: got %v want empty", changed)
+	}
+
+	// new event
+	events = `[{"id":"4","repo":{"name":"ko-da-k/fuga"}},` + events[1:]
+	changed, err = j.changedRepos(context.Background())
+	if err != nil {
+		t.Fatalf("%+v\n", err)
+	}
+	if len(changed) != 1 || !changed["fuga"] {
+		t.Errorf("unexpected changed repositories: got %v want fuga", changed)
+	}
+}
@@ -1,6 +1,7 @@
 package exporter
 
 import (
+	"math"
 	"strconv"
 	"strings"
 	"time"
@@ -8,6 +9,8 @@ import (
 	"github.com/google/go-github/v28/github"
 	"github.com/prometheus/client_golang/prometheus"
 	log "github.com/sirupsen/logrus"
+
+	"github.com/ko-da-k/github-developer-exporter/config"
 )
 
 var (
@@ -58,6 +61,63 @@ var (
 		"reviewer",
 		"label",
 	}
+	workflowRunLabels = []string{
+		"org_name",
+		"repo_name",
+		"workflow",
+		"branch",
+		"status",
+		"conclusion",
+	}
+	pullRequestSizeLabels = []string{
+		"org_name",
+		"repo_name",
+		"number",
+		"state",
+		"size",
+	}
+	pullRequestSizeHistogramLabels = []string{
+		"org_name",
+		"repo_name",
+		"state",
+	}
+	reviewLabels = []string{
+		"org_name",
+		"repo_name",
+		"reviewer",
+		"state",
+	}
+	reviewCommentLabels = []string{
+		"org_name",
+		"repo_name",
+		"reviewer",
+	}
+	staleLabels = []string{
+		"org_name",
+		"repo_name",
+		"kind",
+		"class",
+	}
+	staleAssigneeLabels = []string{
+		"org_name",
+		"assignee",
+		"kind",
+		"class",
+	}
+	oldestUntouchedLabels = []string{
+		"org_name",
+		"repo_name",
+		"kind",
+	}
+	workloadLabels = []string{
+		"org_name",
+		"team",
+		"login",
+	}
+	doraLabels = []string{
+		"org_name",
+		"repo_name",
+	}
 
 	// prometheus description
 	up = prometheus.NewDesc(
@@ -66,6 +126,12 @@ var (
 		nil,
 		nil,
 	)
+	orgUp = prometheus.NewDesc(
+		"org_up",
+		"Was the last query of the organization successful.",
+		[]string{"org"},
+		nil,
+	)
 	orgInfo = prometheus.NewDesc(
 		"org_info",
 		"organization info",
@@ -114,6 +180,126 @@ var (
 		pullRequestLabels,
 		nil,
 	)
+	workflowRunInfo = prometheus.NewDesc(
+		"workflow_run_info",
+		"latest workflow run info given by webhook",
+		workflowRunLabels,
+		nil,
+	)
+	pullRequestAdditions = prometheus.NewDesc(
+		"pull_request_additions",
+		"How many lines the pull request added.",
+		pullRequestSizeLabels,
+		nil,
+	)
+	pullRequestDeletions = prometheus.NewDesc(
+		"pull_request_deletions",
+		"How many lines the pull request deleted.",
+		pullRequestSizeLabels,
+		nil,
+	)
+	pullRequestChangedFiles = prometheus.NewDesc(
+		"pull_request_changed_files",
+		"How many files the pull request changed.",
+		pullRequestSizeLabels,
+		nil,
+	)
+	pullRequestCommits = prometheus.NewDesc(
+		"pull_request_commits",
+		"How many commits the pull request has.",
+		pullRequestSizeLabels,
+		nil,
+	)
+	pullRequestSizeLines = prometheus.NewDesc(
+		"pull_request_size_lines",
+		"Distribution of changed lines (additions + deletions) of open and recently merged pull requests.",
+		pullRequestSizeHistogramLabels,
+		nil,
+	)
+	pullRequestSizeChangedFiles = prometheus.NewDesc(
+		"pull_request_size_changed_files",
+		"Distribution of changed files of open and recently merged pull requests.",
+		pullRequestSizeHistogramLabels,
+		nil,
+	)
+	pullRequestSizeCommits = prometheus.NewDesc(
+		"pull_request_size_commits",
+		"Distribution of commits of open and recently merged pull requests.",
+		pullRequestSizeHistogramLabels,
+		nil,
+	)
+	reviewerReviewsCount = prometheus.NewDesc(
+		"reviewer_reviews_count",
+		"How many reviews the reviewer submitted within the window.",
+		reviewLabels,
+		nil,
+	)
+	reviewerReviewCommentsCount = prometheus.NewDesc(
+		"reviewer_review_comments_count",
+		"How many review comments the reviewer wrote within the window.",
+		reviewCommentLabels,
+		nil,
+	)
+	staleItemsCount = prometheus.NewDesc(
+		"stale_items_count",
+		"How many open pull requests or issues are fresh, stale or abandoned.",
+		staleLabels,
+		nil,
+	)
+	staleItemsByAssigneeCount = prometheus.NewDesc(
+		"stale_items_by_assignee_count",
+		"How many open pull requests or issues assigned to the user are fresh, stale or abandoned.",
+		staleAssigneeLabels,
+		nil,
+	)
+	repoOldestUntouchedSeconds = prometheus.NewDesc(
+		"repo_oldest_untouched_seconds",
+		"How long the oldest untouched open pull request or issue in the repository has been idle.",
+		oldestUntouchedLabels,
+		nil,
+	)
+	userOpenPullRequestsCount = prometheus.NewDesc(
+		"user_open_pull_requests_count",
+		"How many open pull requests the user authored.",
+		workloadLabels,
+		nil,
+	)
+	userPendingReviewRequestsCount = prometheus.NewDesc(
+		"user_pending_review_requests_count",
+		"How many pull requests are waiting for review by the user or the team.",
+		workloadLabels,
+		nil,
+	)
+	userAssignedIssuesCount = prometheus.NewDesc(
+		"user_assigned_issues_count",
+		"How many open issues are assigned to the user.",
+		workloadLabels,
+		nil,
+	)
+	doraLeadTime = prometheus.NewDesc(
+		"dora_lead_time_seconds",
+		"Time from merging a pull request to the first deployment which contains it.",
+		doraLabels,
+		nil,
+	)
+	doraDeploymentsCount = prometheus.NewDesc(
+		"dora_deployments_count",
+		"How many deployments were made within the window.",
+		doraLabels,
+		nil,
+	)
+	doraFailedChangesCount = prometheus.NewDesc(
+		"dora_failed_changes_count",
+		"How many deployments failed or pull requests were reverted within the window.",
+		doraLabels,
+		nil,
+	)
+	doraChangeFailureRate = prometheus.NewDesc(
+		"dora_change_failure_rate",
+		"Ratio of failed changes to deployments within the window.",
+		doraLabels,
+		nil,
+	)
 )
 
 type devCollector struct {
@@ -121,23 +307,69 @@ type devCollector struct {
 }
 
 func NewDevCollector(gs []*GitHubCollector) prometheus.Collector {
-	return &devCollector{gs}
+	return &devCollector{gs: gs}
 }
 
 func (c *devCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- up
+	ch <- orgUp
 	ch <- orgInfo
 	ch <- orgTotalReposCount
 	ch <- orgPublicReposCount
 	ch <- orgPrivateReposCount
 	ch <- repoInfo
 	ch <- repoOpenIssueCount
+	ch <- issueInfo
 	ch <- pullRequestInfo
+	if config.PRSizeConfig.Enabled {
+		ch <- pullRequestAdditions
+		ch <- pullRequestDeletions
+		ch <- pullRequestChangedFiles
+		ch <- pullRequestCommits
+		ch <- pullRequestSizeLines
+		ch <- pullRequestSizeChangedFiles
+		ch <- pullRequestSizeCommits
+	}
+	if config.ReviewConfig.Window > 0 {
+		ch <- reviewerReviewsCount
+		ch <- reviewerReviewCommentsCount
+	}
+	ch <- workflowRunInfo
+	ch <- staleItemsCount
+	ch <- staleItemsByAssigneeCount
+	ch <- repoOldestUntouchedSeconds
+	ch <- userOpenPullRequestsCount
+	ch <- userPendingReviewRequestsCount
+	ch <- userAssignedIssuesCount
+	if config.DORAConfig.Enabled {
+		ch <- doraLeadTime
+		ch <- doraDeploymentsCount
+		ch <- doraFailedChangesCount
+		ch <- doraChangeFailureRate
+	}
 }
 
 func (c *devCollector) Collect(ch chan<- prometheus.Metric) {
-	ok := c.collectOrgsMetrics(ch)
+	// each org is collected independently not to blank the others
+	ok := true
+	for _, g := range c.gs {
+		// metrics are pre-rendered when the job finishes
+		snap := snapshots.Get(g.org)
+		for _, m := range snap.metrics {
+			ch <- m
+		}
+		orgOk := snap.ok
+		orgUpValue := 0.0
+		if orgOk {
+			orgUpValue = 1.0
+		}
+		ch <- prometheus.MustNewConstMetric(
+			orgUp, prometheus.GaugeValue, orgUpValue, g.org,
+		)
+		ok = ok && orgOk
+	}
 
-	// check latest query successfully
+	// check latest query of all orgs successfully
 	if ok {
 		ch <- prometheus.MustNewConstMetric(
 			up, prometheus.GaugeValue, 1.0,
@@ -149,96 +381,125 @@ func (c *devCollector) Collect(ch chan<- prometheus.Metric) {
 	}
 }
 
-// collectOrgsMetrics fetch data from cache and calculate prometheus metrics
-func (c *devCollector) collectOrgsMetrics(ch chan<- prometheus.Metric) bool {
-	for _, g := range c.gs {
-		org, err := g.GetOrg()
+// collectOrgMetrics fetch data of the org from cache and calculate prometheus metrics.
+// It returns false if the org or its repositories are not cached.
+func (c *devCollector) collectOrgMetrics(ch chan<- prometheus.Metric, g *GitHubCollector) bool {
+	org, err := g.GetOrg()
+	if err != nil {
+		log.Errorf("%s data not found: %v", g.org, err)
+		return false
+	}
+	labels := []string{
+		org.GetLogin(),
+		org.GetName(),
+		org.GetURL(),
+		privacy.Email(org.GetEmail()),
+		org.GetBlog(),
+		org.GetCreatedAt().String(),
+		org.GetUpdatedAt().String(),
+	}
+	ch <- prometheus.MustNewConstMetric(
+		orgInfo,
+		prometheus.GaugeValue,
+		1.0,
+		labels...,
+	)
+
+	repos, err := g.GetReposByOrg()
+	if err != nil {
+		log.Errorf("%s repos not found: %v", g.org, err)
+		return false
+	}
+	ch <- prometheus.MustNewConstMetric(
+		orgTotalReposCount,
+		prometheus.GaugeValue,
+		float64(len(repos)),
+		labels...,
+	)
+	publicCnt := 0.0
+	privateCnt := 0.0
+	w := newWorkload()
+	as := newAssigneeStaleness()
+
+	// set repository metrics in this loop
+	for _, repo := range repos {
+		if repo.GetPrivate() {
+			privateCnt++
+		} else {
+			publicCnt++
+		}
+		ref := privacy.repoRef(repo)
+		c.setRepoMetrics(ch, repo, ref)
+
+		// set issue metrics in this loop
+		issues, err := g.GetIssuesByRepo(ref.name)
 		if err != nil {
-			log.Errorf("%s data not found: %v", g.org, err)
-			return false
+			log.Errorf("%s/%s issues not found: %v", g.org, ref.name, err)
 		}
-		labels := []string{
-			org.GetLogin(),
-			org.GetName(),
-			org.GetURL(),
-			org.GetEmail(),
-			org.GetBlog(),
-			org.GetCreatedAt().String(),
-			org.GetUpdatedAt().String(),
+		issueInfos := newInfoCounter()
+		for _, issue := range issues {
+			c.setIssueMetrics(issueInfos, g, ref, issue)
+			w.addIssue(issue)
 		}
-		ch <- prometheus.MustNewConstMetric(
-			orgInfo,
-			prometheus.GaugeValue,
-			1.0,
-			labels...,
-		)
+		issueInfos.collect(ch, issueInfo)
 
-		repos, err := g.GetReposByOrg()
+		// set pull request metrics in this loop
+		pulls, err := g.GetPullRequestsByRepo(ref.name)
 		if err != nil {
-			log.Errorf("%s repos not found: %v", g.org, err)
-			return false
+			log.Errorf("%s/%s pull requests not found: %v", g.org, ref.name, err)
 		}
-		ch <- prometheus.MustNewConstMetric(
-			orgTotalReposCount,
-			prometheus.GaugeValue,
-			float64(len(repos)),
-			labels...,
-		)
-		publicCnt := 0.0
-		privateCnt := 0.0
-
-		// set repository metrics in this loop
-		for _, repo := range repos {
-			if repo.GetPrivate() {
-				privateCnt++
-			} else {
-				publicCnt++
-			}
-			c.setRepoMetrics(ch, repo)
+		pullRequestInfos := newInfoCounter()
+		for _, pull := range pulls {
+			c.setPullRequestMetrics(pullRequestInfos, g, ref, pull)
+			w.addPullRequest(pull)
+		}
+		pullRequestInfos.collect(ch, pullRequestInfo)
 
-			// set issue metrics in this loop
-			issues, err := g.GetIssuesByRepo(repo.GetName())
-			if err != nil {
-				log.Errorf("%s/%s issues not found: %v", g.org, repo.GetName(), err)
-			}
-			for _, issue := range issues {
-				c.setIssueMetrics(ch, g, repo.GetName(), issue)
-			}
+		c.setStalenessMetrics(ch, g, ref, issues, pulls, as)
+		c.setWorkflowRunMetrics(ch, g, ref)
 
-			// set pull request metrics in this loop
-			pulls, err := g.GetPullRequestsByRepo(repo.GetName())
-			if err != nil {
-				log.Errorf("%s/%s pull requests not found: %v", g.org, repo.GetName(), err)
-			}
-			for _, pull := range pulls {
-				c.setPullRequestMetrics(ch, g, repo.GetName(), pull)
-			}
+		if config.PRSizeConfig.Enabled {
+			c.setPullRequestSizeMetrics(ch, g, ref)
+		}
+
+		if config.ReviewConfig.Window > 0 {
+			c.setReviewMetrics(ch, g, ref)
+		}
+
+		if config.DORAConfig.Enabled {
+			c.setDORAMetrics(ch, g, ref, pulls)
 		}
-		ch <- prometheus.MustNewConstMetric(
-			orgPublicReposCount,
-			prometheus.GaugeValue,
-			publicCnt,
-			labels...,
-		)
-		ch <- prometheus.MustNewConstMetric(
-			orgPrivateReposCount,
-			prometheus.GaugeValue,
-			privateCnt,
-			labels...,
-		)
 	}
+	ch <- prometheus.MustNewConstMetric(
+		orgPublicReposCount,
+		prometheus.GaugeValue,
+		publicCnt,
+		labels...,
+	)
+	ch <- prometheus.MustNewConstMetric(
+		orgPrivateReposCount,
+		prometheus.GaugeValue,
+		privateCnt,
+		labels...,
+	)
+	c.setWorkloadMetrics(ch, g, w)
+	c.setStalenessByAssigneeMetrics(ch, g, as)
 	return true
 }
 
-func (c *devCollector) setRepoMetrics(ch chan<- prometheus.Metric, repo *github.Repository) {
+func (c *devCollector) setRepoMetrics(ch chan<- prometheus.Metric, repo *github.Repository, ref repoRef) {
 	// set metrics
+	fullName := repo.GetFullName()
+	if ref.redacted {
+		fullName = repo.GetOwner().GetLogin() + "/" + ref.label
+	}
 	labels := []string{
 		repo.GetOrganization().GetLogin(),
-		repo.GetName(),
-		repo.GetFullName(),
+		ref.label,
+		fullName,
 		repo.GetOwner().GetLogin(),
-		repo.GetURL(),
-		repo.GetDefaultBranch(),
+		ref.text(repo.GetURL()),
+		ref.text(repo.GetDefaultBranch()),
 		strconv.FormatBool(repo.GetArchived()),
 		repo.GetLanguage(),
 		repo.GetCreatedAt().String(),
@@ -259,7 +520,7 @@ func (c *devCollector) setRepoMetrics(ch chan<- prometheus.Metric, repo *github.
 	)
 }
 
-func (c *devCollector) setIssueMetrics(ch chan<- prometheus.Metric, g *GitHubCollector, repoName string, issue *github.Issue) {
+func (c *devCollector) setIssueMetrics(infos *infoCounter, g *GitHubCollector, repo repoRef, issue *github.Issue) {
 	// set label string to prometheus label value
 	// labelName contains multiple labels connected by comma.
 	labelArr := make([]string, len(issue.Labels))
@@ -269,24 +530,19 @@ func (c *devCollector) setIssueMetrics(ch chan<- prometheus.Metric, g *GitHubCol
 	labelName := strings.Join(labelArr, ",")
 	labels := []string{
 		g.org,
-		repoName,
+		repo.label,
 		issue.GetState(),
-		issue.GetTitle(),
+		repo.text(privacy.Title(issue.GetTitle())),
 		issue.GetCreatedAt().String(),
 		issue.GetUpdatedAt().String(),
 		formatTime(issue.GetClosedAt()),
-		issue.GetAssignee().GetLogin(),
-		labelName,
+		privacy.Login(issue.GetAssignee().GetLogin()),
+		repo.text(labelName),
 	}
-	ch <- prometheus.MustNewConstMetric(
-		issueInfo,
-		prometheus.GaugeValue,
-		1.0,
-		labels...,
-	)
+	infos.add(labels)
 }
 
-func (c *devCollector) setPullRequestMetrics(ch chan<- prometheus.Metric, g *GitHubCollector, repoName string, pull *github.PullRequest) {
+func (c *devCollector) setPullRequestMetrics(infos *infoCounter, g *GitHubCollector, repo repoRef, pull *github.PullRequest) {
 	// set label string to prometheus label value
 	// labelname contains multiple labels connected by comma.
 	labelArr := make([]string, len(pull.Labels))
@@ -299,29 +555,439 @@ func (c *devCollector) setPullRequestMetrics(ch chan<- prometheus.Metric, g *Git
 	// reviewers contains multiple reviewers connected by comma.
 	reviewerArr := make([]string, len(pull.RequestedReviewers))
 	for i, reviewer := range pull.RequestedReviewers {
-		reviewerArr[i] = reviewer.GetLogin()
+		reviewerArr[i] = privacy.Login(reviewer.GetLogin())
 	}
 	reviewers := strings.Join(reviewerArr, ",")
 
 	labels := []string{
 		g.org,
-		repoName,
+		repo.label,
 		pull.GetState(),
-		pull.GetTitle(),
+		repo.text(privacy.Title(pull.GetTitle())),
 		pull.GetCreatedAt().String(),
 		pull.GetUpdatedAt().String(),
 		formatTime(pull.GetClosedAt()),
 		formatTime(pull.GetMergedAt()),
-		pull.GetAssignee().GetLogin(),
+		privacy.Login(pull.GetAssignee().GetLogin()),
 		reviewers,
-		labelName,
+		repo.text(labelName),
+	}
+	infos.add(labels)
+}
+
+func (c *devCollector) setPullRequestSizeMetrics(ch chan<- prometheus.Metric, g *GitHubCollector, repo repoRef) {
+	details, err := g.GetPullRequestDetailsByRepo(repo.name)
+	if err != nil {
+		log.Errorf("%s/%s pull request details not found: %v", g.org, repo.name, err)
+		return
+	}
+
+	lineBuckets := make([]float64, len(config.PRSizeConfig.Buckets))
+	for i, b := range config.PRSizeConfig.Buckets {
+		lineBuckets[i] = float64(b)
+	}
+	// key is pull request state
+	lines := make(map[string]*histogram)
+	files := make(map[string]*histogram)
+	commits := make(map[string]*histogram)
+
+	for _, pull := range details {
+		state := pullRequestStatus(pull)
+		changed := pull.GetAdditions() + pull.GetDeletions()
+		labels := []string{
+			g.org,
+			repo.label,
+			strconv.Itoa(pull.GetNumber()),
+			state,
+			sizeLabel(changed, config.PRSizeConfig.Buckets),
+		}
+		ch <- prometheus.MustNewConstMetric(
+			pullRequestAdditions,
+			prometheus.GaugeValue,
+			float64(pull.GetAdditions()),
+			labels...,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			pullRequestDeletions,
+			prometheus.GaugeValue,
+			float64(pull.GetDeletions()),
+			labels...,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			pullRequestChangedFiles,
+			prometheus.GaugeValue,
+			float64(pull.GetChangedFiles()),
+			labels...,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			pullRequestCommits,
+			prometheus.GaugeValue,
+			float64(pull.GetCommits()),
+			labels...,
+		)
+
+		if _, ok := lines[state]; !ok {
+			lines[state] = newHistogram(lineBuckets)
+			files[state] = newHistogram(changedFilesBuckets)
+			commits[state] = newHistogram(commitsBuckets)
+		}
+		url := repo.text(pull.GetHTMLURL())
+		lines[state].observePullRequest(float64(changed), url, pull.GetNumber())
+		files[state].observePullRequest(float64(pull.GetChangedFiles()), url, pull.GetNumber())
+		commits[state].observePullRequest(float64(pull.GetCommits()), url, pull.GetNumber())
+	}
+
+	for state := range lines {
+		for desc, h := range map[*prometheus.Desc]*histogram{
+			pullRequestSizeLines:        lines[state],
+			pullRequestSizeChangedFiles: files[state],
+			pullRequestSizeCommits:      commits[state],
+		} {
+			ch <- h.metric(desc, g.org, repo.label, state)
+		}
+	}
+}
+
+// setWorkflowRunMetrics sets the latest run of each workflow.
+// workflow runs are cached only if webhook is configured.
+func (c *devCollector) setWorkflowRunMetrics(ch chan<- prometheus.Metric, g *GitHubCollector, repo repoRef) {
+	runs, err := g.GetWorkflowRunsByRepo(repo.name)
+	if err != nil {
+		return
+	}
+	infos := newInfoCounter()
+	for _, run := range runs {
+		infos.add([]string{
+			g.org,
+			repo.label,
+			repo.text(run.GetName()),
+			repo.text(run.GetHeadBranch()),
+			run.GetStatus(),
+			run.GetConclusion(),
+		})
+	}
+	infos.collect(ch, workflowRunInfo)
+}
+
+// setStalenessMetrics classifies open pull requests and issues by how long they are untouched.
+// last activity of pull requests includes reviews and review comments if they are fetched.
+func (c *devCollector) setStalenessMetrics(ch chan<- prometheus.Metric, g *GitHubCollector, repo repoRef, issues []*github.Issue, pulls []*github.PullRequest, as *assigneeStaleness) {
+	now := time.Now()
+	var lastReview map[int]time.Time
+	if config.ReviewConfig.Window > 0 {
+		reviews, _ := g.GetReviewsByRepo(repo.name)
+		comments, _ := g.GetReviewCommentsByRepo(repo.name)
+		lastReview = lastReviewActivity(reviews, comments)
+	}
+
+	for _, kind := range []string{"pull_request", "issue"} {
+		counts := make(map[string]int, len(stalenessClasses))
+		var oldest time.Duration
+		evaluate := func(labelNames []string, assignees []*github.User, last time.Time) {
+			idle := now.Sub(last)
+			class := staleness.Classify(g.org, repo.name, labelNames, idle)
+			counts[class]++
+			as.add(kind, assignees, class)
+			if idle > oldest {
+				oldest = idle
+			}
+		}
+
+		if kind == "pull_request" {
+			for _, pull := range pulls {
+				if pull.GetState() != "open" {
+					continue
+				}
+				labelNames := make([]string, len(pull.Labels))
+				for i, label := range pull.Labels {
+					labelNames[i] = label.GetName()
+				}
+				evaluate(labelNames, pull.Assignees, latest(pull.GetUpdatedAt(), lastReview[pull.GetNumber()]))
+			}
+		} else {
+			for _, issue := range issues {
+				if issue.GetState() != "open" {
+					continue
+				}
+				labelNames := make([]string, len(issue.Labels))
+				for i, label := range issue.Labels {
+					labelNames[i] = label.GetName()
+				}
+				evaluate(labelNames, issue.Assignees, issue.GetUpdatedAt())
+			}
+		}
+
+		for _, class := range stalenessClasses {
+			ch <- prometheus.MustNewConstMetric(
+				staleItemsCount,
+				prometheus.GaugeValue,
+				float64(counts[class]),
+				g.org,
+				repo.label,
+				kind,
+				class,
+			)
+		}
+		ch <- prometheus.MustNewConstMetric(
+			repoOldestUntouchedSeconds,
+			prometheus.GaugeValue,
+			oldest.Seconds(),
+			g.org,
+			repo.label,
+			kind,
+		)
+	}
+}
+
+func (c *devCollector) setStalenessByAssigneeMetrics(ch chan<- prometheus.Metric, g *GitHubCollector, as *assigneeStaleness) {
+	for k, cnt := range as.counts {
+		ch <- prometheus.MustNewConstMetric(
+			staleItemsByAssigneeCount,
+			prometheus.GaugeValue,
+			float64(cnt),
+			g.org,
+			k[1],
+			k[0],
+			k[2],
+		)
+	}
+}
+
+// setReviewMetrics sets how many reviews and review comments each reviewer submitted within the window
+func (c *devCollector) setReviewMetrics(ch chan<- prometheus.Metric, g *GitHubCollector, repo repoRef) {
+	since := time.Now().AddDate(0, 0, -config.ReviewConfig.Window)
+
+	reviews, err := g.GetReviewsByRepo(repo.name)
+	if err != nil {
+		log.Errorf("%s/%s reviews not found: %v", g.org, repo.name, err)
+		return
+	}
+	type reviewKey struct {
+		reviewer string
+		state    string
+	}
+	reviewCnt := make(map[reviewKey]int)
+	for _, rs := range reviews {
+		for _, r := range rs {
+			// pending reviews are not submitted yet
+			if r.GetSubmittedAt().Before(since) {
+				continue
+			}
+			reviewCnt[reviewKey{privacy.Login(r.GetUser().GetLogin()), r.GetState()}]++
+		}
+	}
+	for k, cnt := range reviewCnt {
+		ch <- prometheus.MustNewConstMetric(
+			reviewerReviewsCount,
+			prometheus.GaugeValue,
+			float64(cnt),
+			g.org,
+			repo.label,
+			k.reviewer,
+			k.state,
+		)
+	}
+
+	comments, err := g.GetReviewCommentsByRepo(repo.name)
+	if err != nil {
+		log.Errorf("%s/%s review comments not found: %v", g.org, repo.name, err)
+		return
+	}
+	commentCnt := make(map[string]int)
+	for _, comment := range comments {
+		if comment.GetCreatedAt().Before(since) {
+			continue
+		}
+		commentCnt[privacy.Login(comment.GetUser().GetLogin())]++
+	}
+	for reviewer, cnt := range commentCnt {
+		ch <- prometheus.MustNewConstMetric(
+			reviewerReviewCommentsCount,
+			prometheus.GaugeValue,
+			float64(cnt),
+			g.org,
+			repo.label,
+			reviewer,
+		)
+	}
+}
+
+// setWorkloadMetrics sets workload metrics for each team the user belongs to.
+// users who belong to no team have empty team label.
+// review requests to a team itself have empty login label.
+// users are aggregated by label value, which may be the same for different users if logins are dropped or truncated.
+func (c *devCollector) setWorkloadMetrics(ch chan<- prometheus.Metric, g *GitHubCollector, w *workload) {
+	members, err := g.GetTeamMembersByOrg()
+	if err != nil {
+		log.Errorf("%s team members not found: %v", g.org, err)
+	}
+	teams := teamsByLogin(members)
+
+	type workloadKey struct {
+		team  string
+		login string
+	}
+	type workloadCounts struct {
+		// hasUser is false for review requests to a team itself
+		hasUser          bool
+		openPullRequests int
+		pendingReviews   int
+		assignedIssues   int
+	}
+	counts := make(map[workloadKey]*workloadCounts)
+	var keys []workloadKey
+	countsOf := func(k workloadKey) *workloadCounts {
+		if _, ok := counts[k]; !ok {
+			counts[k] = &workloadCounts{}
+			keys = append(keys, k)
+		}
+		return counts[k]
+	}
+	for _, login := range w.logins() {
+		userTeams := teams[login]
+		if len(userTeams) == 0 {
+			userTeams = []string{""}
+		}
+		for _, team := range userTeams {
+			cnt := countsOf(workloadKey{team, privacy.Login(login)})
+			cnt.hasUser = true
+			cnt.openPullRequests += w.openPullRequests[login]
+			cnt.pendingReviews += w.pendingReviews[login]
+			cnt.assignedIssues += w.assignedIssues[login]
+		}
+	}
+	for team, cnt := range w.pendingTeamReviews {
+		countsOf(workloadKey{team, ""}).pendingReviews += cnt
+	}
+
+	for _, k := range keys {
+		cnt := counts[k]
+		labels := []string{
+			g.org,
+			k.team,
+			k.login,
+		}
+		if cnt.hasUser {
+			ch <- prometheus.MustNewConstMetric(
+				userOpenPullRequestsCount,
+				prometheus.GaugeValue,
+				float64(cnt.openPullRequests),
+				labels...,
+			)
+			ch <- prometheus.MustNewConstMetric(
+				userAssignedIssuesCount,
+				prometheus.GaugeValue,
+				float64(cnt.assignedIssues),
+				labels...,
+			)
+		}
+		ch <- prometheus.MustNewConstMetric(
+			userPendingReviewRequestsCount,
+			prometheus.GaugeValue,
+			float64(cnt.pendingReviews),
+			labels...,
+		)
+	}
+}
+
+func (c *devCollector) setDORAMetrics(ch chan<- prometheus.Metric, g *GitHubCollector, repo repoRef, pulls []*github.PullRequest) {
+	deployments, err := g.GetDeploymentsByRepo(repo.name)
+	if err != nil {
+		log.Errorf("%s/%s deployments not found: %v", g.org, repo.name, err)
+		return
+	}
+	deliveries, err := g.GetDeliveriesByRepo(repo.name)
+	if err != nil {
+		log.Errorf("%s/%s deliveries not found: %v", g.org, repo.name, err)
+		return
+	}
+	labels := []string{
+		g.org,
+		repo.label,
+	}
+
+	h := leadTimeHistogram(deliveries)
+	if repo.redacted {
+		// exemplars have URLs of pull requests
+		h.exemplars = nil
+	}
+	ch <- h.metric(doraLeadTime, labels...)
+
+	// failed changes are failed deployments and merged revert pull requests
+	since := time.Now().AddDate(0, 0, -config.DORAConfig.Window)
+	deployed := 0.0
+	failed := 0.0
+	for _, d := range deployments {
+		if d.Succeeded() {
+			deployed++
+		} else if d.Failed() {
+			deployed++
+			failed++
+		}
+	}
+	for _, pull := range pulls {
+		if isRevert(pull) && pull.GetMergedAt().After(since) {
+			failed++
+		}
 	}
 	ch <- prometheus.MustNewConstMetric(
-		pullRequestInfo,
+		doraDeploymentsCount,
 		prometheus.GaugeValue,
-		1.0,
+		deployed,
 		labels...,
 	)
+	ch <- prometheus.MustNewConstMetric(
+		doraFailedChangesCount,
+		prometheus.GaugeValue,
+		failed,
+		labels...,
+	)
+	rate := 0.0
+	if deployed > 0 {
+		rate = math.Min(failed/deployed, 1.0)
+	}
+	ch <- prometheus.MustNewConstMetric(
+		doraChangeFailureRate,
+		prometheus.GaugeValue,
+		rate,
+		labels...,
+	)
+}
+
+// infoCounter counts items which have the same label values of an info metric.
+// The value is 1 unless privacy settings drop or truncate labels which distinguish the items.
+type infoCounter struct {
+	// keys keep the order of the first items
+	keys   []string
+	labels map[string][]string
+	counts map[string]int
+}
+
+func newInfoCounter() *infoCounter {
+	return &infoCounter{
+		labels: make(map[string][]string),
+		counts: make(map[string]int),
+	}
+}
+
+func (c *infoCounter) add(labels []string) {
+	key := strings.Join(labels, "\xff")
+	if _, ok := c.counts[key]; !ok {
+		c.keys = append(c.keys, key)
+		c.labels[key] = labels
+	}
+	c.counts[key]++
+}
+
+func (c *infoCounter) collect(ch chan<- prometheus.Metric, desc *prometheus.Desc) {
+	for _, key := range c.keys {
+		ch <- prometheus.MustNewConstMetric(
+			desc,
+			prometheus.GaugeValue,
+			float64(c.counts[key]),
+			c.labels[key]...,
+		)
+	}
 }
 
 // formatTime returns empty if t is zero
@@ -0,0 +1,70 @@
+package exporter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v28/github"
+	"github.com/patrickmn/go-cache"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// setTestCache caches an org which has a repository with an issue and a pull request
+func setTestCache(orgName string) {
+	now := time.Now()
+	Kv.Set(orgName, &github.Organization{Login: github.String(orgName)}, cache.DefaultExpiration)
+	Kv.Set(orgName+"-repos", []*github.Repository{
+		{Name: github.String("hoge"), Owner: &github.User{Login: github.String(orgName)}},
+	}, cache.DefaultExpiration)
+	Kv.Set(orgName+"-hoge-issues", []*github.Issue{
+		{Number: github.Int(1), State: github.String("open"), UpdatedAt: &now},
+	}, cache.DefaultExpiration)
+	Kv.Set(orgName+"-hoge-pulls", []*github.PullRequest{
+		{Number: github.Int(2), State: github.String("open"), UpdatedAt: &now, User: &github.User{Login: github.String("alice")}},
+	}, cache.DefaultExpiration)
+}
+
+// gather returns metric families by name
+func gather(t *testing.T, c prometheus.Collector) map[string]*dto.MetricFamily {
+	reg := prometheus.NewPedanticRegistry()
+	if err := reg.Register(c); err != nil {
+		t.Fatalf("%+v\n", err)
+	}
+	mfs, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("%+v\n", err)
+	}
+	families := make(map[string]*dto.MetricFamily, len(mfs))
+	for _, mf := range mfs {
+		families[mf.GetName()] = mf
+	}
+	return families
+}
+
+func TestCollectorOrgsAreIndependent(t *testing.T) {
+	setTestCache("healthy")
+	defer Kv.Flush()
+
+	c := NewDevCollector([]*GitHubCollector{
+		NewGitHubCollector("missing"),
+		NewGitHubCollector("healthy"),
+	})
+	families := gather(t, c)
+
+	orgUps := make(map[string]float64)
+	for _, m := range families["org_up"].GetMetric() {
+		orgUps[m.GetLabel()[0].GetValue()] = m.GetGauge().GetValue()
+	}
+	if orgUps["missing"] != 0 || orgUps["healthy"] != 1 {
+		t.Errorf("unexpected org_up: got %v", orgUps)
+	}
+	if got := families["up"].GetMetric()[0].GetGauge().GetValue(); got != 0 {
+		t.Errorf("unexpected up: got %v want %v", got, 0)
+	}
+	for _, name := range []string{"org_info", "repo_info", "issue_info", "pull_request_info"} {
+		if _, ok := families[name]; !ok {
+			t.Errorf("%s of healthy org should be collected", name)
+		}
+	}
+}
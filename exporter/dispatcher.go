@@ -3,6 +3,7 @@ package exporter
 import (
 	"context"
 	"sync"
+	"sync/atomic"
 
 	log "github.com/sirupsen/logrus"
 
@@ -14,24 +15,44 @@ type Dispatcher struct {
 	jobQueue   chan *Job
 	worker     Worker
 	wg         sync.WaitGroup
+	// running is false if the loop has stopped
+	running atomic.Bool
+	// active is jobs taken from the queue and not finished yet
+	active atomic.Int32
+	// onIdle is called when all queued jobs have finished
+	onIdle func(ctx context.Context)
+	// idling is true while onIdle is running
+	idling atomic.Bool
 }
 
 func NewDispatcher(worker Worker) *Dispatcher {
 	pool := make(chan struct{}, config.ServerConfig.MaxWorker)
 	queue := make(chan *Job, config.ServerConfig.MaxQueue)
 	return &Dispatcher{
-		pool,
-		queue,
-		worker,
-		sync.WaitGroup{},
+		workerPool: pool,
+		jobQueue:   queue,
+		worker:     worker,
 	}
 }
 
+// OnIdle sets f called when the last running job finishes and no job is queued,
+// i.e. once after all jobs added at a tick. It must be set before Start.
+// It does not hold a worker, and it is skipped if the previous call is still running.
+func (d *Dispatcher) OnIdle(f func(ctx context.Context)) {
+	d.onIdle = f
+}
+
 func (d *Dispatcher) Start(ctx context.Context) {
 	d.wg.Add(1)
+	d.running.Store(true)
 	go d.run(ctx)
 }
 
+// Running returns false before start or after the loop has stopped
+func (d *Dispatcher) Running() bool {
+	return d.running.Load()
+}
+
 func (d *Dispatcher) Wait() {
 	d.wg.Wait()
 }
@@ -40,11 +61,22 @@ func (d *Dispatcher) Add(job *Job) {
 	d.jobQueue <- job
 }
 
+// TryAdd adds the job unless the queue is full
+func (d *Dispatcher) TryAdd(job *Job) bool {
+	select {
+	case d.jobQueue <- job:
+		return true
+	default:
+		return false
+	}
+}
+
 func (d *Dispatcher) Stop() {
 	d.wg.Done()
 }
 
 func (d *Dispatcher) run(ctx context.Context) {
+	defer d.running.Store(false)
 	wg := sync.WaitGroup{}
 	// starting n number of workers
 	for {
@@ -52,14 +84,19 @@ func (d *Dispatcher) run(ctx context.Context) {
 		case job := <-d.jobQueue:
 			// increment the waitgroup
 			wg.Add(1)
+			d.active.Add(1)
 			d.workerPool <- struct{}{}
 
 			go func(job *Job) {
 				defer wg.Done()
-				defer func() { <-d.workerPool }()
 
 				log.Infof("%s job started", job.orgName)
 				d.worker.Work(ctx, job)
+				<-d.workerPool
+				if d.active.Add(-1) == 0 && len(d.jobQueue) == 0 && d.onIdle != nil && d.idling.CompareAndSwap(false, true) {
+					d.onIdle(ctx)
+					d.idling.Store(false)
+				}
 			}(job)
 		case <-ctx.Done():
 			wg.Wait()
@@ -0,0 +1,84 @@
+package exporter
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v28/github"
+)
+
+var (
+	// leadTimeBuckets are upper bounds (second) of lead time histogram.
+	// 1h, 4h, 1d, 3d, 1w, 2w, 30d
+	leadTimeBuckets = []float64{
+		3600,
+		4 * 3600,
+		24 * 3600,
+		3 * 24 * 3600,
+		7 * 24 * 3600,
+		14 * 24 * 3600,
+		30 * 24 * 3600,
+	}
+)
+
+// Deployment is a change delivered to production.
+// It is made from a GitHub deployment or, if the repository has no deployment, from a release.
+type Deployment struct {
+	// Source is "deployment" or "release"
+	Source    string
+	Ref       string
+	SHA       string
+	CreatedAt time.Time
+	// State is the latest deployment status. release is always "success".
+	State string
+}
+
+// Succeeded returns true if the change reached production.
+// "inactive" means the deployment succeeded and then was superseded.
+func (d *Deployment) Succeeded() bool {
+	return d.State == "success" || d.State == "inactive"
+}
+
+// Failed returns true if the deployment status is failure or error.
+func (d *Deployment) Failed() bool {
+	return d.State == "failure" || d.State == "error"
+}
+
+// Delivery links a merged pull request to the first deployment which contains its merge commit.
+type Delivery struct {
+	Number     int
+	URL        string
+	MergedAt   time.Time
+	DeployedAt time.Time
+}
+
+// LeadTime returns the duration from merge to deployment.
+func (d *Delivery) LeadTime() time.Duration {
+	return d.DeployedAt.Sub(d.MergedAt)
+}
+
+// newDeploymentFromRelease converts a release to Deployment
+func newDeploymentFromRelease(release *github.RepositoryRelease) *Deployment {
+	return &Deployment{
+		Source:    "release",
+		Ref:       release.GetTagName(),
+		SHA:       release.GetTargetCommitish(),
+		CreatedAt: release.GetPublishedAt().Time,
+		State:     "success",
+	}
+}
+
+// isRevert returns true if the pull request reverts another one.
+// GitHub names revert pull requests `Revert "<original title>"`.
+func isRevert(pull *github.PullRequest) bool {
+	return strings.HasPrefix(pull.GetTitle(), "Revert \"")
+}
+
+// leadTimeHistogram aggregates lead times into prometheus histogram values.
+func leadTimeHistogram(deliveries []*Delivery) *histogram {
+	h := newHistogram(leadTimeBuckets)
+	for _, d := range deliveries {
+		h.observePullRequest(d.LeadTime().Seconds(), d.URL, d.Number)
+	}
+	return h
+}
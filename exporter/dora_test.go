@@ -0,0 +1,55 @@
+package exporter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v28/github"
+)
+
+func TestLeadTimeHistogram(t *testing.T) {
+	merged := time.Date(2019, 11, 1, 0, 0, 0, 0, time.UTC)
+	deliveries := []*Delivery{
+		{Number: 1, MergedAt: merged, DeployedAt: merged.Add(30 * time.Minute)},
+		{Number: 2, MergedAt: merged, DeployedAt: merged.Add(2 * time.Hour)},
+		{Number: 3, MergedAt: merged, DeployedAt: merged.Add(48 * time.Hour)},
+	}
+
+	h := leadTimeHistogram(deliveries)
+
+	if h.count != 3 {
+		t.Errorf("unexpected count: got %v want %v", h.count, 3)
+	}
+	expectedSum := (30*time.Minute + 2*time.Hour + 48*time.Hour).Seconds()
+	if h.sum != expectedSum {
+		t.Errorf("unexpected sum: got %v want %v", h.sum, expectedSum)
+	}
+	expected := map[float64]uint64{
+		3600:           1,
+		4 * 3600:       2,
+		24 * 3600:      2,
+		3 * 24 * 3600:  3,
+		7 * 24 * 3600:  3,
+		14 * 24 * 3600: 3,
+		30 * 24 * 3600: 3,
+	}
+	for b, want := range expected {
+		if got := h.buckets[b]; got != want {
+			t.Errorf("unexpected bucket %v: got %v want %v", b, got, want)
+		}
+	}
+}
+
+func TestIsRevert(t *testing.T) {
+	cases := map[string]bool{
+		`Revert "add feature"`: true,
+		"add feature":          false,
+		"Revert typo":          false,
+	}
+	for title, expected := range cases {
+		pull := &github.PullRequest{Title: github.String(title)}
+		if actual := isRevert(pull); actual != expected {
+			t.Errorf("isRevert(%q): got %v want %v", title, actual, expected)
+		}
+	}
+}
@@ -0,0 +1,288 @@
+package exporter
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v28/github"
+	"github.com/patrickmn/go-cache"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+// WorkflowRunEvent is triggered when a GitHub Actions workflow run is requested or completed.
+// go-github v28 does not support it, so only the fields we use are defined.
+//
+// GitHub API docs: https://docs.github.com/en/webhooks/webhook-events-and-payloads#workflow_run
+type WorkflowRunEvent struct {
+	Action      *string              `json:"action,omitempty"`
+	WorkflowRun *WorkflowRun         `json:"workflow_run,omitempty"`
+	Repo        *github.Repository   `json:"repository,omitempty"`
+	Org         *github.Organization `json:"organization,omitempty"`
+}
+
+// WorkflowRun is the latest run of a workflow
+type WorkflowRun struct {
+	ID         *int64     `json:"id,omitempty"`
+	Name       *string    `json:"name,omitempty"`
+	HeadBranch *string    `json:"head_branch,omitempty"`
+	HeadSHA    *string    `json:"head_sha,omitempty"`
+	Event      *string    `json:"event,omitempty"`
+	Status     *string    `json:"status,omitempty"`
+	Conclusion *string    `json:"conclusion,omitempty"`
+	HTMLURL    *string    `json:"html_url,omitempty"`
+	CreatedAt  *time.Time `json:"created_at,omitempty"`
+	UpdatedAt  *time.Time `json:"updated_at,omitempty"`
+}
+
+var (
+	// eventMu serializes read-modify-write of cache by events
+	eventMu sync.Mutex
+
+	// deliveries remembers X-GitHub-Delivery not to count redelivered events twice.
+	// GitHub allows redelivery of recent deliveries only.
+	deliveries = cache.New(72*time.Hour, time.Hour)
+
+	// event counters are fed by webhook, so they show rates
+	// which snapshot gauges cannot, e.g. a pull request opened and merged between two jobs.
+	pullRequestEventsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "github_pull_request_events_total",
+			Help: "How many pull request webhook events were received. closed with merged is counted as merged.",
+		},
+		[]string{"org", "repo", "action"},
+	)
+	issueEventsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "github_issue_events_total",
+			Help: "How many issue webhook events were received.",
+		},
+		[]string{"org", "repo", "action"},
+	)
+	reviewSubmittedTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "github_review_submitted_total",
+			Help: "How many reviews were submitted.",
+		},
+		[]string{"reviewer", "state"},
+	)
+)
+
+// ApplyEvent updates the cached data of the org/repo by a webhook event and counts it.
+// Events of orgs or repositories which are not cached yet are not applied
+// because the next job fetches all of them.
+// Redelivered events which have the same deliveryID are ignored.
+func ApplyEvent(deliveryID string, event interface{}) error {
+	eventMu.Lock()
+	defer eventMu.Unlock()
+
+	if deliveryID != "" {
+		if err := deliveries.Add(deliveryID, struct{}{}, cache.DefaultExpiration); err != nil {
+			log.Debugf("delivery %s was already received", deliveryID)
+			return nil
+		}
+	}
+
+	var repo *github.Repository
+	switch e := event.(type) {
+	case *github.PullRequestEvent:
+		repo = e.GetRepo()
+		action := e.GetAction()
+		if action == "closed" && e.GetPullRequest().GetMerged() {
+			action = "merged"
+		}
+		pullRequestEventsTotal.WithLabelValues(e.GetRepo().GetOwner().GetLogin(), privacy.repoRef(e.GetRepo()).label, action).Inc()
+		applyPullRequest(e.GetRepo(), e.GetPullRequest())
+	case *github.PullRequestReviewEvent:
+		repo = e.GetRepo()
+		if e.GetAction() == "submitted" {
+			// webhook gives lowercase state while API gives uppercase
+			reviewSubmittedTotal.WithLabelValues(privacy.Login(e.GetReview().GetUser().GetLogin()), strings.ToUpper(e.GetReview().GetState())).Inc()
+		}
+		applyPullRequestReview(e.GetRepo(), e.GetPullRequest(), e.GetReview())
+	case *github.IssuesEvent:
+		repo = e.GetRepo()
+		// pull requests are counted by pull request events
+		if !e.GetIssue().IsPullRequest() {
+			issueEventsTotal.WithLabelValues(e.GetRepo().GetOwner().GetLogin(), privacy.repoRef(e.GetRepo()).label, e.GetAction()).Inc()
+		}
+		applyIssue(e.GetRepo(), e.GetIssue())
+	case *github.RepositoryEvent:
+		repo = e.GetRepo()
+		applyRepository(e.GetAction(), e.GetRepo())
+	case *WorkflowRunEvent:
+		repo = e.Repo
+		applyWorkflowRun(e.Repo, e.WorkflowRun)
+	default:
+		return fmt.Errorf("unsupported event type %T", event)
+	}
+	snapshots.Invalidate(repo.GetOwner().GetLogin())
+	return nil
+}
+
+func applyPullRequest(repo *github.Repository, pull *github.PullRequest) {
+	org := repo.GetOwner().GetLogin()
+	key := fmt.Sprintf("%s-%s-pulls", org, repo.GetName())
+	psi, found := Kv.Get(key)
+	pulls, ok := psi.([]*github.PullRequest)
+	if !found || !ok {
+		log.Debugf("%s/%s pull requests are not cached yet", org, repo.GetName())
+		return
+	}
+	// copy not to modify the slice being read by collector
+	updated := make([]*github.PullRequest, 0, len(pulls)+1)
+	updated = append(updated, pull)
+	for _, p := range pulls {
+		if p.GetNumber() != pull.GetNumber() {
+			updated = append(updated, p)
+		}
+	}
+	Kv.Set(key, updated, cache.DefaultExpiration)
+
+	// pull request in webhook payload has additions, deletions and so on
+	detailKey := fmt.Sprintf("%s-%s-pull-details", org, repo.GetName())
+	if di, found := Kv.Get(detailKey); found {
+		if details, ok := di.(map[int]*github.PullRequest); ok {
+			updatedDetails := make(map[int]*github.PullRequest, len(details)+1)
+			for n, d := range details {
+				updatedDetails[n] = d
+			}
+			updatedDetails[pull.GetNumber()] = pull
+			Kv.Set(detailKey, updatedDetails, cache.DefaultExpiration)
+		}
+	}
+}
+
+func applyPullRequestReview(repo *github.Repository, pull *github.PullRequest, review *github.PullRequestReview) {
+	org := repo.GetOwner().GetLogin()
+	key := fmt.Sprintf("%s-%s-reviews", org, repo.GetName())
+	rsi, found := Kv.Get(key)
+	reviews, ok := rsi.(map[int][]*github.PullRequestReview)
+	if !found || !ok {
+		log.Debugf("%s/%s reviews are not cached yet", org, repo.GetName())
+		return
+	}
+	updated := make(map[int][]*github.PullRequestReview, len(reviews)+1)
+	for n, rs := range reviews {
+		updated[n] = rs
+	}
+	number := pull.GetNumber()
+	rs := make([]*github.PullRequestReview, 0, len(reviews[number])+1)
+	for _, r := range reviews[number] {
+		if r.GetID() != review.GetID() {
+			rs = append(rs, r)
+		}
+	}
+	updated[number] = append(rs, review)
+	Kv.Set(key, updated, cache.DefaultExpiration)
+}
+
+func applyIssue(repo *github.Repository, issue *github.Issue) {
+	// pull requests are also issues but cached separately
+	if issue.IsPullRequest() {
+		return
+	}
+	org := repo.GetOwner().GetLogin()
+	key := fmt.Sprintf("%s-%s-issues", org, repo.GetName())
+	ii, found := Kv.Get(key)
+	issues, ok := ii.([]*github.Issue)
+	if !found || !ok {
+		log.Debugf("%s/%s issues are not cached yet", org, repo.GetName())
+		return
+	}
+	updated := make([]*github.Issue, 0, len(issues)+1)
+	updated = append(updated, issue)
+	for _, i := range issues {
+		if i.GetNumber() != issue.GetNumber() {
+			updated = append(updated, i)
+		}
+	}
+	Kv.Set(key, updated, cache.DefaultExpiration)
+}
+
+func applyRepository(action string, repo *github.Repository) {
+	org := repo.GetOwner().GetLogin()
+	key := fmt.Sprintf("%s-repos", org)
+	rsi, found := Kv.Get(key)
+	repos, ok := rsi.([]*github.Repository)
+	if !found || !ok {
+		log.Debugf("%s repos are not cached yet", org)
+		return
+	}
+	updated := make([]*github.Repository, 0, len(repos)+1)
+	for _, r := range repos {
+		if r.GetID() != repo.GetID() {
+			updated = append(updated, r)
+		}
+	}
+	// deleted or transferred repository no longer belongs to the org
+	if action != "deleted" && action != "transferred" {
+		updated = append(updated, repo)
+	}
+	Kv.Set(key, updated, cache.DefaultExpiration)
+}
+
+func applyWorkflowRun(repo *github.Repository, run *WorkflowRun) {
+	if run == nil {
+		return
+	}
+	org := repo.GetOwner().GetLogin()
+	if _, found := Kv.Get(fmt.Sprintf("%s-repos", org)); !found {
+		log.Debugf("%s repos are not cached yet", org)
+		return
+	}
+	// workflow runs are only given by webhook, so start from empty
+	key := fmt.Sprintf("%s-%s-workflow-runs", org, repo.GetName())
+	runs := make(map[string]*WorkflowRun)
+	if wi, found := Kv.Get(key); found {
+		if prev, ok := wi.(map[string]*WorkflowRun); ok {
+			for name, r := range prev {
+				runs[name] = r
+			}
+		}
+	}
+	// keep the latest run of each workflow
+	if prev, ok := runs[run.GetName()]; ok && prev.GetCreatedAt().After(run.GetCreatedAt()) {
+		return
+	}
+	runs[run.GetName()] = run
+	// workflow runs are not refreshed by job, so they never expire
+	Kv.Set(key, runs, cache.NoExpiration)
+}
+
+func (r *WorkflowRun) GetName() string {
+	if r == nil || r.Name == nil {
+		return ""
+	}
+	return *r.Name
+}
+
+func (r *WorkflowRun) GetHeadBranch() string {
+	if r == nil || r.HeadBranch == nil {
+		return ""
+	}
+	return *r.HeadBranch
+}
+
+func (r *WorkflowRun) GetStatus() string {
+	if r == nil || r.Status == nil {
+		return ""
+	}
+	return *r.Status
+}
+
+func (r *WorkflowRun) GetConclusion() string {
+	if r == nil || r.Conclusion == nil {
+		return ""
+	}
+	return *r.Conclusion
+}
+
+func (r *WorkflowRun) GetCreatedAt() time.Time {
+	if r == nil || r.CreatedAt == nil {
+		return time.Time{}
+	}
+	return *r.CreatedAt
+}
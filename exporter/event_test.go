@@ -0,0 +1,97 @@
+package exporter
+
+import (
+	"testing"
+
+	"github.com/google/go-github/v28/github"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestApplyEventCountsOnce(t *testing.T) {
+	repo := &github.Repository{
+		Name:  github.String("hoge"),
+		Owner: &github.User{Login: github.String("ko-da-k")},
+	}
+	merged := &github.PullRequestEvent{
+		Action:      github.String("closed"),
+		PullRequest: &github.PullRequest{Number: github.Int(1), Merged: github.Bool(true)},
+		Repo:        repo,
+	}
+	review := &github.PullRequestReviewEvent{
+		Action: github.String("submitted"),
+		Review: &github.PullRequestReview{
+			ID:    github.Int64(1),
+			User:  &github.User{Login: github.String("alice")},
+			State: github.String("approved"),
+		},
+		PullRequest: &github.PullRequest{Number: github.Int(1)},
+		Repo:        repo,
+	}
+
+	// the second one is redelivery
+	for i := 0; i < 2; i++ {
+		if err := ApplyEvent("delivery-1", merged); err != nil {
+			t.Fatalf("%+v\n", err)
+		}
+		if err := ApplyEvent("delivery-2", review); err != nil {
+			t.Fatalf("%+v\n", err)
+		}
+	}
+
+	if got := testutil.ToFloat64(pullRequestEventsTotal.WithLabelValues("ko-da-k", "hoge", "merged")); got != 1 {
+		t.Errorf("unexpected pull request events: got %v want %v", got, 1)
+	}
+	if got := testutil.ToFloat64(reviewSubmittedTotal.WithLabelValues("alice", "APPROVED")); got != 1 {
+		t.Errorf("unexpected review submitted: got %v want %v", got, 1)
+	}
+}
+
+func TestApplyEventPrivacy(t *testing.T) {
+	defaultPrivacy := privacy
+	defer func() { privacy = defaultPrivacy }()
+	privacy, _ = NewPrivacyPolicy("", "", "hash", "key", "secret", false)
+
+	repo := &github.Repository{
+		Name:     github.String("secret"),
+		FullName: github.String("ko-da-k/secret"),
+		Owner:    &github.User{Login: github.String("ko-da-k")},
+	}
+	events := []interface{}{
+		&github.PullRequestEvent{
+			Action:      github.String("opened"),
+			PullRequest: &github.PullRequest{Number: github.Int(1)},
+			Repo:        repo,
+		},
+		&github.IssuesEvent{
+			Action: github.String("opened"),
+			Issue:  &github.Issue{Number: github.Int(2)},
+			Repo:   repo,
+		},
+		&github.PullRequestReviewEvent{
+			Action: github.String("submitted"),
+			Review: &github.PullRequestReview{
+				ID:    github.Int64(2),
+				User:  &github.User{Login: github.String("bob")},
+				State: github.String("commented"),
+			},
+			PullRequest: &github.PullRequest{Number: github.Int(1)},
+			Repo:        repo,
+		},
+	}
+	for _, event := range events {
+		if err := ApplyEvent("", event); err != nil {
+			t.Fatalf("%+v\n", err)
+		}
+	}
+
+	label := privacy.repoRef(repo).label
+	if got := testutil.ToFloat64(pullRequestEventsTotal.WithLabelValues("ko-da-k", label, "opened")); got != 1 {
+		t.Errorf("unexpected pull request events of redacted repository: got %v want %v", got, 1)
+	}
+	if got := testutil.ToFloat64(issueEventsTotal.WithLabelValues("ko-da-k", label, "opened")); got != 1 {
+		t.Errorf("unexpected issue events of redacted repository: got %v want %v", got, 1)
+	}
+	if got := testutil.ToFloat64(reviewSubmittedTotal.WithLabelValues(privacy.Login("bob"), "COMMENTED")); got != 1 {
+		t.Errorf("unexpected review submitted by hashed reviewer: got %v want %v", got, 1)
+	}
+}
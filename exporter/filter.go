@@ -0,0 +1,154 @@
+package exporter
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// MetricFilter selects metrics of a scrape by org, repository and metric family.
+// It filters gathered metrics, so exporter and runtime metrics can also be selected.
+// A zero value selects all metrics.
+type MetricFilter struct {
+	orgs map[string]bool
+	repo *regexp.Regexp
+	// families are prefixes of metric names, e.g. "pull_request" selects pull_request_info
+	families []string
+}
+
+// NewMetricFilter parses filters given as URL query values.
+// Each of orgs and families may also be comma separated.
+// repo is a regular expression which must match a whole repository name.
+func NewMetricFilter(orgs []string, repo string, families []string) (*MetricFilter, error) {
+	f := &MetricFilter{families: splitValues(families)}
+	if o := splitValues(orgs); len(o) > 0 {
+		f.orgs = make(map[string]bool, len(o))
+		for _, org := range o {
+			f.orgs[org] = true
+		}
+	}
+	if repo != "" {
+		re, err := regexp.Compile("^(?:" + repo + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("invalid repo pattern %q: %w", repo, err)
+		}
+		f.repo = re
+	}
+	return f, nil
+}
+
+func splitValues(values []string) []string {
+	var ret []string
+	for _, v := range values {
+		for _, s := range strings.Split(v, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				ret = append(ret, s)
+			}
+		}
+	}
+	return ret
+}
+
+// Orgs returns the selected orgs, or nil if all orgs are selected
+func (f *MetricFilter) Orgs() []string {
+	if f.orgs == nil {
+		return nil
+	}
+	orgs := make([]string, 0, len(f.orgs))
+	for org := range f.orgs {
+		orgs = append(orgs, org)
+	}
+	sort.Strings(orgs)
+	return orgs
+}
+
+func (f *MetricFilter) matchOrg(org string) bool {
+	return f.orgs == nil || f.orgs[org]
+}
+
+// matchFamily reports whether metrics of the family may be selected
+func (f *MetricFilter) matchFamily(family string) bool {
+	if len(f.families) == 0 {
+		return true
+	}
+	for _, prefix := range f.families {
+		if strings.HasPrefix(family, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// match reports whether the metric of the family is selected.
+// Metrics which do not belong to an org are not dropped by orgs,
+// but metrics which do not belong to a repository are dropped if repo pattern is set.
+func (f *MetricFilter) match(family string, m *dto.Metric) bool {
+	org, repo := "", ""
+	orgFound, repoFound := false, false
+	repoLabel := repoLabelName(family)
+	for _, l := range m.GetLabel() {
+		switch name := l.GetName(); {
+		case name == "org_name" || name == "org":
+			org, orgFound = l.GetValue(), true
+		case name == repoLabel || name == "repo":
+			repo, repoFound = l.GetValue(), true
+		}
+	}
+	if orgFound && !f.matchOrg(org) {
+		return false
+	}
+	// up and org_up of the selected orgs are always selected
+	if family == "up" || family == "org_up" {
+		return true
+	}
+	if f.repo != nil && (!repoFound || !f.repo.MatchString(repo)) {
+		return false
+	}
+	return f.matchFamily(family)
+}
+
+// repoLabelName returns the label name of the repository name in the family.
+// Exporter metrics such as webhook events have it as "repo".
+func repoLabelName(family string) string {
+	// repository metrics have repository name as "name"
+	if family == "repo_info" || family == "repo_open_issue_count" {
+		return "name"
+	}
+	return "repo_name"
+}
+
+// NewFilteredGatherer returns the gatherer which selects metrics gathered by g.
+// It is an error if some of the orgs are not configured.
+func NewFilteredGatherer(g prometheus.Gatherer, gs []*GitHubCollector, filter *MetricFilter) (prometheus.Gatherer, error) {
+	configured := 0
+	for _, c := range gs {
+		if filter.matchOrg(c.org) {
+			configured++
+		}
+	}
+	if configured < len(filter.orgs) {
+		return nil, fmt.Errorf("orgs %v are not all configured", filter.Orgs())
+	}
+	return prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		// gathered families are still filtered like promhttp.ContinueOnError
+		mfs, err := g.Gather()
+		filtered := make([]*dto.MetricFamily, 0, len(mfs))
+		for _, mf := range mfs {
+			var metrics []*dto.Metric
+			for _, m := range mf.GetMetric() {
+				if filter.match(mf.GetName(), m) {
+					metrics = append(metrics, m)
+				}
+			}
+			if len(metrics) > 0 {
+				mf.Metric = metrics
+				filtered = append(filtered, mf)
+			}
+		}
+		return filtered, err
+	}), nil
+}
@@ -0,0 +1,112 @@
+package exporter
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// newLabeledMetric returns a metric which has the label pairs
+func newLabeledMetric(pairs ...string) *dto.Metric {
+	m := &dto.Metric{}
+	for i := 0; i+1 < len(pairs); i += 2 {
+		name, value := pairs[i], pairs[i+1]
+		m.Label = append(m.Label, &dto.LabelPair{Name: &name, Value: &value})
+	}
+	return m
+}
+
+func TestMetricFilter(t *testing.T) {
+	f, err := NewMetricFilter([]string{"ko-da-k,foo"}, "ho.*", []string{"pull_request", "repo_info", "github_exporter_"})
+	if err != nil {
+		t.Fatalf("%+v\n", err)
+	}
+	cases := []struct {
+		family string
+		metric *dto.Metric
+		want   bool
+	}{
+		{"pull_request_info", newLabeledMetric("org_name", "ko-da-k", "repo_name", "hoge"), true},
+		{"pull_request_size_lines", newLabeledMetric("org_name", "foo", "repo_name", "hoge"), true},
+		{"repo_info", newLabeledMetric("org_name", "ko-da-k", "name", "hoge"), true},
+		{"issue_info", newLabeledMetric("org_name", "ko-da-k", "repo_name", "hoge"), false},
+		{"pull_request_info", newLabeledMetric("org_name", "ko-da-k", "repo_name", "fuga"), false},
+		{"pull_request_info", newLabeledMetric("org_name", "bar", "repo_name", "hoge"), false},
+		// repo pattern must match a whole name
+		{"pull_request_info", newLabeledMetric("org_name", "ko-da-k", "repo_name", "shoge"), false},
+		{"org_info", newLabeledMetric("org_name", "ko-da-k"), false},
+		// exporter metrics have org and repo labels
+		{"github_exporter_repo_fetch_errors", newLabeledMetric("org", "ko-da-k", "repo", "hoge", "reason", "timeout"), true},
+		{"github_exporter_http_cache_hits_total", newLabeledMetric(), false},
+		{"org_up", newLabeledMetric("org", "ko-da-k"), true},
+		{"org_up", newLabeledMetric("org", "bar"), false},
+		{"up", newLabeledMetric(), true},
+	}
+	for _, c := range cases {
+		if got := f.match(c.family, c.metric); got != c.want {
+			t.Errorf("%s %v: got %v want %v", c.family, c.metric.GetLabel(), got, c.want)
+		}
+	}
+	if got := f.Orgs(); len(got) != 2 || got[0] != "foo" || got[1] != "ko-da-k" {
+		t.Errorf("unexpected orgs: got %v", got)
+	}
+
+	if _, err := NewMetricFilter(nil, "(", nil); err == nil {
+		t.Errorf("invalid repo pattern should be an error")
+	}
+}
+
+func TestFilteredGatherer(t *testing.T) {
+	setTestCache("healthy")
+	setTestCache("other")
+	defer Kv.Flush()
+	snapshots.Refresh("healthy")
+	snapshots.Refresh("other")
+	gs := []*GitHubCollector{
+		NewGitHubCollector("healthy"),
+		NewGitHubCollector("other"),
+	}
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(NewDevCollector(gs), httpCacheHitsTotal)
+	httpCacheHitsTotal.Add(0)
+
+	gather := func(f *MetricFilter) map[string]*dto.MetricFamily {
+		g, err := NewFilteredGatherer(reg, gs, f)
+		if err != nil {
+			t.Fatalf("%+v\n", err)
+		}
+		mfs, err := g.Gather()
+		if err != nil {
+			t.Fatalf("%+v\n", err)
+		}
+		families := make(map[string]*dto.MetricFamily, len(mfs))
+		for _, mf := range mfs {
+			families[mf.GetName()] = mf
+		}
+		return families
+	}
+
+	f, _ := NewMetricFilter([]string{"healthy"}, "", []string{"issue"})
+	families := gather(f)
+	if len(families["org_up"].GetMetric()) != 1 {
+		t.Errorf("org_up of other org should be dropped: %v", families["org_up"])
+	}
+	if len(families["issue_info"].GetMetric()) != 1 {
+		t.Errorf("issue_info of healthy org should be collected: %v", families["issue_info"])
+	}
+	if _, ok := families["pull_request_info"]; ok {
+		t.Errorf("pull_request_info should be dropped")
+	}
+
+	// metrics of the main registry other than the dev collector can be selected
+	f, _ = NewMetricFilter(nil, "", []string{"github_exporter_"})
+	if _, ok := gather(f)["github_exporter_http_cache_hits_total"]; !ok {
+		t.Errorf("github_exporter_http_cache_hits_total should be collected")
+	}
+
+	f, _ = NewMetricFilter([]string{"missing"}, "", nil)
+	if _, err := NewFilteredGatherer(reg, gs, f); err == nil {
+		t.Errorf("unknown org should be an error")
+	}
+}
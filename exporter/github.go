@@ -3,6 +3,8 @@ package exporter
 import (
 	"context"
 	"fmt"
+	"net/http"
+
 	"github.com/google/go-github/v28/github"
 	"golang.org/x/oauth2"
 
@@ -16,8 +18,15 @@ type GitHubCollector struct {
 type collector interface {
 	GetOrg() (*github.Organization, error)
 	GetReposByOrg() ([]*github.Repository, error)
+	GetTeamMembersByOrg() (map[string][]*github.User, error)
 	GetIssuesByRepo(repoName string) ([]*github.Issue, error)
 	GetPullRequestsByRepo(repoName string) ([]*github.PullRequest, error)
+	GetPullRequestDetailsByRepo(repoName string) (map[int]*github.PullRequest, error)
+	GetReviewsByRepo(repoName string) (map[int][]*github.PullRequestReview, error)
+	GetReviewCommentsByRepo(repoName string) ([]*github.PullRequestComment, error)
+	GetDeploymentsByRepo(repoName string) ([]*Deployment, error)
+	GetWorkflowRunsByRepo(repoName string) (map[string]*WorkflowRun, error)
+	GetDeliveriesByRepo(repoName string) ([]*Delivery, error)
 }
 
 var _ collector = (*GitHubCollector)(nil)
@@ -26,6 +35,11 @@ func NewGitHubCollector(org string) *GitHubCollector {
 	return &GitHubCollector{org}
 }
 
+// Org returns the organization name
+func (g *GitHubCollector) Org() string {
+	return g.org
+}
+
 func (g *GitHubCollector) GetOrg() (*github.Organization, error) {
 	oi, found := Kv.Get(g.org)
 	org, ok := oi.(*github.Organization)
@@ -50,6 +64,18 @@ func (g *GitHubCollector) GetReposByOrg() ([]*github.Repository, error) {
 	return repos, nil
 }
 
+func (g *GitHubCollector) GetTeamMembersByOrg() (map[string][]*github.User, error) {
+	mi, found := Kv.Get(fmt.Sprintf("%s-team-members", g.org))
+	members, ok := mi.(map[string][]*github.User)
+	if !found {
+		return nil, fmt.Errorf("%s team members not found in cache", g.org)
+	}
+	if !ok {
+		return nil, fmt.Errorf("type conversion failed")
+	}
+	return members, nil
+}
+
 func (g *GitHubCollector) GetIssuesByRepo(repoName string) ([]*github.Issue, error) {
 	ii, found := Kv.Get(fmt.Sprintf("%s-%s-issues", g.org, repoName))
 	issues, ok := ii.([]*github.Issue)
@@ -74,8 +100,87 @@ func (g *GitHubCollector) GetPullRequestsByRepo(repoName string) ([]*github.Pull
 	return pulls, nil
 }
 
+func (g *GitHubCollector) GetPullRequestDetailsByRepo(repoName string) (map[int]*github.PullRequest, error) {
+	psi, found := Kv.Get(fmt.Sprintf("%s-%s-pull-details", g.org, repoName))
+	details, ok := psi.(map[int]*github.PullRequest)
+	if !found {
+		return nil, fmt.Errorf("%s/%s pull request details not found in cache", g.org, repoName)
+	}
+	if !ok {
+		return nil, fmt.Errorf("type conversion failed")
+	}
+	return details, nil
+}
+
+func (g *GitHubCollector) GetReviewsByRepo(repoName string) (map[int][]*github.PullRequestReview, error) {
+	rsi, found := Kv.Get(fmt.Sprintf("%s-%s-reviews", g.org, repoName))
+	reviews, ok := rsi.(map[int][]*github.PullRequestReview)
+	if !found {
+		return nil, fmt.Errorf("%s/%s reviews not found in cache", g.org, repoName)
+	}
+	if !ok {
+		return nil, fmt.Errorf("type conversion failed")
+	}
+	return reviews, nil
+}
+
+func (g *GitHubCollector) GetReviewCommentsByRepo(repoName string) ([]*github.PullRequestComment, error) {
+	csi, found := Kv.Get(fmt.Sprintf("%s-%s-review-comments", g.org, repoName))
+	comments, ok := csi.([]*github.PullRequestComment)
+	if !found {
+		return nil, fmt.Errorf("%s/%s review comments not found in cache", g.org, repoName)
+	}
+	if !ok {
+		return nil, fmt.Errorf("type conversion failed")
+	}
+	return comments, nil
+}
+
+func (g *GitHubCollector) GetDeploymentsByRepo(repoName string) ([]*Deployment, error) {
+	dsi, found := Kv.Get(fmt.Sprintf("%s-%s-deployments", g.org, repoName))
+	deployments, ok := dsi.([]*Deployment)
+	if !found {
+		return nil, fmt.Errorf("%s/%s deployments not found in cache", g.org, repoName)
+	}
+	if !ok {
+		return nil, fmt.Errorf("type conversion failed")
+	}
+	return deployments, nil
+}
+
+func (g *GitHubCollector) GetDeliveriesByRepo(repoName string) ([]*Delivery, error) {
+	dsi, found := Kv.Get(fmt.Sprintf("%s-%s-deliveries", g.org, repoName))
+	deliveries, ok := dsi.([]*Delivery)
+	if !found {
+		return nil, fmt.Errorf("%s/%s deliveries not found in cache", g.org, repoName)
+	}
+	if !ok {
+		return nil, fmt.Errorf("type conversion failed")
+	}
+	return deliveries, nil
+}
+
+func (g *GitHubCollector) GetWorkflowRunsByRepo(repoName string) (map[string]*WorkflowRun, error) {
+	wi, found := Kv.Get(fmt.Sprintf("%s-%s-workflow-runs", g.org, repoName))
+	runs, ok := wi.(map[string]*WorkflowRun)
+	if !found {
+		return nil, fmt.Errorf("%s/%s workflow runs not found in cache", g.org, repoName)
+	}
+	if !ok {
+		return nil, fmt.Errorf("type conversion failed")
+	}
+	return runs, nil
+}
+
 // NewGitHubClient constructor
 func NewGitHubClient(ctx context.Context) (*github.Client, error) {
+	transport, err := newTransport()
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize transport: %w", err)
+	}
+	// oauth2 transport uses the client in context as the base
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, &http.Client{Transport: transport})
+
 	ts := oauth2.StaticTokenSource(
 		&oauth2.Token{
 			AccessToken: config.GitHubConfig.Token,
@@ -90,3 +195,31 @@ func NewGitHubClient(ctx context.Context) (*github.Client, error) {
 	}
 	return client, nil
 }
+
+// newTransport returns the transport under oauth2 transport
+func newTransport() (http.RoundTripper, error) {
+	transport := http.DefaultTransport
+	if config.GitHubConfig.HTTPCache {
+		maxBytes := int64(config.GitHubConfig.CacheMaxMB) << 20
+		var store responseStore = newMemoryStore(maxBytes, config.GitHubConfig.CacheTTL)
+		if config.GitHubConfig.CacheDir != "" {
+			ds, err := newDiskStore(config.GitHubConfig.CacheDir, maxBytes, config.GitHubConfig.CacheTTL)
+			if err != nil {
+				return nil, err
+			}
+			store = ds
+		}
+		transport = newCachingTransport(transport, store)
+	}
+	transport = &retryTransport{
+		base: transport,
+		policy: retryPolicy{
+			maxAttempts: config.GitHubConfig.RetryMaxAttempts,
+			baseDelay:   config.GitHubConfig.RetryBaseDelay,
+			maxDelay:    config.GitHubConfig.RetryMaxDelay,
+		},
+	}
+	// 304 Not Modified also has rate limit headers
+	transport = &rateLimitTransport{transport, rateLimit}
+	return newTracingTransport(transport), nil
+}
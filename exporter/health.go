@@ -0,0 +1,77 @@
+package exporter
+
+import (
+	"fmt"
+	"time"
+)
+
+// Check is a result of a readiness or liveness check
+type Check struct {
+	Name    string `json:"name"`
+	OK      bool   `json:"ok"`
+	Message string `json:"message"`
+	// Optional checks are reported by `?verbose` but do not fail the endpoint
+	Optional bool `json:"optional,omitempty"`
+}
+
+// ReadinessChecks pass when every org has completed at least one successful job.
+// Collected data are kept only in memory, so there is no persisted cache to be loaded instead.
+// A job is successful once the org and its repositories are fetched,
+// and failed repositories are reported as optional checks not to keep the pod unready.
+func (s *Scheduler) ReadinessChecks() []Check {
+	checks := make([]Check, 0, 2*len(s.jobs))
+	for _, job := range s.jobs {
+		status := job.Status()
+		check := Check{Name: "org:" + job.orgName, OK: !status.LastSuccessAt.IsZero()}
+		if check.OK {
+			check.Message = fmt.Sprintf("last succeeded at %s", status.LastSuccessAt.Format(time.RFC3339))
+		} else if status.LastError != "" {
+			check.Message = "no successful job yet: " + status.LastError
+		} else {
+			check.Message = "no successful job yet"
+		}
+		checks = append(checks, check)
+
+		repos := Check{Name: "repos:" + job.orgName, OK: status.FailedRepos == 0, Optional: true}
+		if repos.OK {
+			repos.Message = "no failed repository"
+		} else {
+			repos.Message = failedReposMessage(status.FailedRepoReasons)
+		}
+		checks = append(checks, repos)
+	}
+	return checks
+}
+
+// LivenessChecks fail if the dispatcher has stopped
+// or no job has finished within maxIntervals intervals.
+// Job progress is not checked while the scheduler is paused.
+func (s *Scheduler) LivenessChecks(maxIntervals int) []Check {
+	dispatcher := Check{Name: "dispatcher", OK: s.dispatcher.Running(), Message: "running"}
+	if !dispatcher.OK {
+		dispatcher.Message = "stopped"
+	}
+
+	s.mu.RLock()
+	paused, last := s.paused, s.startedAt
+	s.mu.RUnlock()
+	for _, job := range s.jobs {
+		if finished := job.Status().LastFinishedAt; finished.After(last) {
+			last = finished
+		}
+	}
+	limit := time.Duration(maxIntervals) * s.interval
+	progress := Check{Name: "job_progress", OK: true}
+	switch {
+	case paused:
+		progress.Message = "scheduler is paused"
+	case last.IsZero():
+		progress.Message = "scheduler is not started"
+	case time.Since(last) > limit:
+		progress.OK = false
+		progress.Message = fmt.Sprintf("no job has finished since %s (limit %s)", last.Format(time.RFC3339), limit)
+	default:
+		progress.Message = fmt.Sprintf("last progress at %s", last.Format(time.RFC3339))
+	}
+	return []Check{dispatcher, progress}
+}
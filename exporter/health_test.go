@@ -0,0 +1,62 @@
+package exporter
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLivenessChecks(t *testing.T) {
+	job := NewJob(nil, "ko-da-k")
+	s := NewScheduler(NewDispatcher(NewWorker()), []*Job{job}, time.Minute)
+	s.dispatcher.running.Store(true)
+
+	cases := []struct {
+		name      string
+		startedAt time.Time
+		finished  time.Time
+		paused    bool
+		ok        bool
+	}{
+		{"recently started", time.Now(), time.Time{}, false, true},
+		{"no progress", time.Now().Add(-time.Hour), time.Time{}, false, false},
+		{"recently finished", time.Now().Add(-time.Hour), time.Now(), false, true},
+		{"paused", time.Now().Add(-time.Hour), time.Time{}, true, true},
+	}
+	for _, c := range cases {
+		s.startedAt = c.startedAt
+		s.paused = c.paused
+		job.status.status.LastFinishedAt = c.finished
+		checks := s.LivenessChecks(3)
+		if !checks[0].OK {
+			t.Errorf("%s: dispatcher should be running", c.name)
+		}
+		if checks[1].OK != c.ok {
+			t.Errorf("%s: got %v want %v (%s)", c.name, checks[1].OK, c.ok, checks[1].Message)
+		}
+	}
+}
+
+func TestReadinessChecks(t *testing.T) {
+	job := NewJob(nil, "ko-da-k")
+	s := NewScheduler(NewDispatcher(NewWorker()), []*Job{job}, time.Minute)
+
+	checks := s.ReadinessChecks()
+	if len(checks) != 2 || checks[0].OK || !checks[1].OK {
+		t.Errorf("unexpected checks before the first job: %+v", checks)
+	}
+
+	// failed repositories do not block readiness
+	job.status.finish(time.Now(), map[string]string{"deleted": "not_found"}, RepoErrors{"deleted": errors.New("not found")})
+	checks = s.ReadinessChecks()
+	if !checks[0].OK {
+		t.Errorf("org should be ready: %+v", checks[0])
+	}
+	if checks[1].OK || !checks[1].Optional || !strings.Contains(checks[1].Message, "deleted") {
+		t.Errorf("unexpected repos check: %+v", checks[1])
+	}
+	if status := job.Status(); status.LastOutcome != outcomePartial {
+		t.Errorf("unexpected outcome: got %v want %v", status.LastOutcome, outcomePartial)
+	}
+}
@@ -2,27 +2,102 @@ package exporter
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/google/go-github/v28/github"
 	"github.com/patrickmn/go-cache"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/ko-da-k/github-developer-exporter/config"
+)
+
+var (
+	repoFetchErrors = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "github_exporter_repo_fetch_errors",
+			Help: "Repositories failed in the last job. The last known good data of them are still exported.",
+		},
+		[]string{"org", "repo", "reason"},
+	)
 )
 
 type Job struct {
 	client  *github.Client
 	orgName string
+
+	// mu prevents concurrent execution of the same org
+	// and protects the state of events polling
+	mu           sync.Mutex
+	eventsETag   string
+	lastEventID  string
+	lastFullSync time.Time
+	// failedRepos are repositories failed in the last execution keyed by repository name
+	failedRepos map[string]repoFailure
+
+	status jobStatus
+	// fullSyncRequested makes the next execution fetch all repositories after the cache is purged
+	fullSyncRequested atomic.Bool
 }
 
 func NewJob(client *github.Client, orgName string) *Job {
-	return &Job{client, orgName}
+	return &Job{
+		client:  client,
+		orgName: orgName,
+	}
 }
 
-func (j *Job) Execute(ctx context.Context) error {
+func (j *Job) Execute(ctx context.Context) (err error) {
+	ctx, span := tracer.Start(ctx, "Job.Execute", trace.WithAttributes(attribute.String("org", j.orgName)))
+	defer func() { endSpan(span, err) }()
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.status.start(time.Now())
+	defer func() { j.status.finish(time.Now(), j.failedRepoReasons(), err) }()
+	// scrapes serve the snapshot until the next job even if this job failed
+	defer snapshots.Refresh(j.orgName)
+
 	if err := j.setCacheByOrg(ctx); err != nil {
 		return fmt.Errorf("failed to set %s org: %w", j.orgName, err)
 	}
-	if err := j.setCacheByRepo(ctx); err != nil {
-		return fmt.Errorf("failed to set repositories in %s org", j.orgName)
+
+	if j.fullSyncRequested.Swap(false) {
+		j.lastEventID = ""
+		j.eventsETag = ""
+	}
+
+	// nil means all repositories
+	var changed map[string]bool
+	if config.GitHubConfig.EventsPolling {
+		var err error
+		if changed, err = j.changedRepos(ctx); err != nil {
+			return fmt.Errorf("failed to poll %s events: %w", j.orgName, err)
+		}
+		// events are consumed even if their repositories failed, so retry the failed ones
+		if changed != nil {
+			for repoName := range j.failedRepos {
+				changed[repoName] = true
+			}
+		}
+	}
+	err = j.setCacheByRepo(ctx, changed)
+	var errs RepoErrors
+	errors.As(err, &errs)
+	j.recordRepoErrors(errs)
+	if err != nil {
+		return fmt.Errorf("failed to set repositories in %s org: %w", j.orgName, err)
 	}
 	return nil
 }
@@ -55,10 +130,77 @@ func (j *Job) setCacheByOrg(ctx context.Context) error {
 	}
 	// send object to global cache Kv
 	Kv.Set(fmt.Sprintf("%s-repos", j.orgName), allRepos, cache.DefaultExpiration)
+
+	// fetch teams and their members in the organization
+	members, err := j.listTeamMembers(ctx)
+	if _, ok := err.(*github.RateLimitError); ok {
+		return fmt.Errorf("access Rate Limit: %w", err)
+	} else if err != nil {
+		// token without read:org scope cannot list teams.
+		// workload metrics are still exported without team.
+		log.Warnf("failed to fetch teams in %s org: %v", j.orgName, err)
+		members = map[string][]*github.User{}
+	}
+	Kv.Set(fmt.Sprintf("%s-team-members", j.orgName), members, cache.DefaultExpiration)
 	return nil
 }
 
-func (j *Job) setCacheByRepo(ctx context.Context) error {
+// listTeamMembers returns members of each team keyed by team slug
+func (j *Job) listTeamMembers(ctx context.Context) (map[string][]*github.User, error) {
+	teamOption := &github.ListOptions{PerPage: 100}
+	var allTeams []*github.Team
+	for {
+		teams, resp, err := j.client.Teams.ListTeams(ctx, j.orgName, teamOption)
+		if err != nil {
+			return nil, err
+		}
+		allTeams = append(allTeams, teams...)
+		if resp.NextPage == 0 {
+			break
+		}
+		teamOption.Page = resp.NextPage
+	}
+
+	members := make(map[string][]*github.User, len(allTeams))
+	for _, team := range allTeams {
+		memberOption := &github.TeamListTeamMembersOptions{
+			ListOptions: github.ListOptions{PerPage: 100},
+		}
+		for {
+			users, resp, err := j.client.Teams.ListTeamMembers(ctx, team.GetID(), memberOption)
+			if err != nil {
+				return nil, err
+			}
+			members[team.GetSlug()] = append(members[team.GetSlug()], users...)
+			if resp.NextPage == 0 {
+				break
+			}
+			memberOption.Page = resp.NextPage
+		}
+	}
+	return members, nil
+}
+
+// RepoErrors are errors of each repository in a job
+type RepoErrors map[string]error
+
+func (e RepoErrors) Error() string {
+	names := make([]string, 0, len(e))
+	for name := range e {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	msgs := make([]string, len(names))
+	for i, name := range names {
+		msgs[i] = fmt.Sprintf("%s: %v", name, e[name])
+	}
+	return fmt.Sprintf("%d repositories failed: %s", len(e), strings.Join(msgs, "; "))
+}
+
+// setCacheByRepo fetches data of repositories concurrently up to RepoConcurrency.
+// If changed is not nil, only changed repositories and ones not cached yet are fetched.
+// A failed repository does not stop the others, and the errors are returned as RepoErrors.
+func (j *Job) setCacheByRepo(ctx context.Context, changed map[string]bool) error {
 	// read repositories from cache
 	ri, found := Kv.Get(fmt.Sprintf("%s-repos", j.orgName))
 	repos, ok := ri.([]*github.Repository)
@@ -66,6 +208,56 @@ func (j *Job) setCacheByRepo(ctx context.Context) error {
 		return fmt.Errorf("failed to read repositories from cache")
 	}
 
+	// rate limit error stops the rest of repositories
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs = make(RepoErrors)
+	)
+	sem := make(chan struct{}, config.GitHubConfig.RepoConcurrency)
+	for _, repo := range repos {
+		// keep the cache of unchanged repositories alive
+		if changed != nil && !changed[repo.GetName()] && touchRepoCache(j.orgName, repo.GetName()) {
+			continue
+		}
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			touchRepoCache(j.orgName, repo.GetName())
+			mu.Lock()
+			errs[repo.GetName()] = ctx.Err()
+			mu.Unlock()
+			continue
+		}
+		wg.Add(1)
+		go func(repoName string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			if err := j.setCacheByRepoName(ctx, repoName); err != nil {
+				if errors.As(err, new(*github.RateLimitError)) {
+					cancel()
+				}
+				// keep serving the last known good data
+				touchRepoCache(j.orgName, repoName)
+				mu.Lock()
+				errs[repoName] = err
+				mu.Unlock()
+			}
+		}(repo.GetName())
+	}
+	wg.Wait()
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (j *Job) setCacheByRepoName(ctx context.Context, repoName string) error {
 	// fetch issues in the repository
 	issueListOption := &github.IssueListByRepoOptions{
 		State:     "all",
@@ -89,29 +281,379 @@ func (j *Job) setCacheByRepo(ctx context.Context) error {
 			PerPage: 100, // Limited
 		},
 	}
-	for _, repo := range repos {
-		pulls, _, err := j.client.PullRequests.List(ctx, j.orgName, repo.GetName(), prListOption)
+
+	pulls, _, err := j.client.PullRequests.List(ctx, j.orgName, repoName, prListOption)
+	if _, ok := err.(*github.RateLimitError); ok {
+		return fmt.Errorf("Access Rate Limit: %w", err)
+	} else if err != nil {
+		return fmt.Errorf("Failed to fetch %s pulls: %w", repoName, err)
+	}
+	Kv.Set(fmt.Sprintf("%s-%s-pulls", j.orgName, repoName), pulls, cache.DefaultExpiration)
+
+	issues := make([]*github.Issue, 0)
+	allIssue, _, err := j.client.Issues.ListByRepo(ctx, j.orgName, repoName, issueListOption)
+	if _, ok := err.(*github.RateLimitError); ok {
+		return fmt.Errorf("Access Rate Limit: %w", err)
+	} else if err != nil {
+		return fmt.Errorf("Failed to fetch %s issues: %w", repoName, err)
+	}
+	// filter by issues not pull requests
+	for _, issue := range allIssue {
+		if !issue.IsPullRequest() {
+			issues = append(issues, issue)
+		}
+	}
+	Kv.Set(fmt.Sprintf("%s-%s-issues", j.orgName, repoName), issues, cache.DefaultExpiration)
+
+	if config.PRSizeConfig.Enabled {
+		if err := j.setPullRequestDetailCacheByRepo(ctx, repoName, pulls); err != nil {
+			return err
+		}
+	}
+
+	if config.ReviewConfig.Window > 0 {
+		if err := j.setReviewCacheByRepo(ctx, repoName, pulls); err != nil {
+			return err
+		}
+	}
+
+	if config.DORAConfig.Enabled {
+		if err := j.setDORACacheByRepo(ctx, repoName, pulls); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setPullRequestDetailCacheByRepo fetches details of open and recently merged pull requests
+// because list API does not return additions, deletions, changed files and commits.
+func (j *Job) setPullRequestDetailCacheByRepo(ctx context.Context, repoName string, pulls []*github.PullRequest) error {
+	since := time.Now().AddDate(0, 0, -config.PRSizeConfig.Window)
+
+	// reuse the previous detail if the pull request is not updated
+	prev := make(map[int]*github.PullRequest)
+	if pi, found := Kv.Get(fmt.Sprintf("%s-%s-pull-details", j.orgName, repoName)); found {
+		if p, ok := pi.(map[int]*github.PullRequest); ok {
+			prev = p
+		}
+	}
+
+	// key is pull request number
+	details := make(map[int]*github.PullRequest)
+	for _, pull := range pulls {
+		if pull.GetState() != "open" && pull.GetMergedAt().Before(since) {
+			continue
+		}
+		if p, ok := prev[pull.GetNumber()]; ok && p.GetUpdatedAt().Equal(pull.GetUpdatedAt()) {
+			details[pull.GetNumber()] = p
+			continue
+		}
+		detail, _, err := j.client.PullRequests.Get(ctx, j.orgName, repoName, pull.GetNumber())
 		if _, ok := err.(*github.RateLimitError); ok {
 			return fmt.Errorf("Access Rate Limit: %w", err)
 		} else if err != nil {
-			return fmt.Errorf("Failed to fetch %s pulls: %w", repo.GetName(), err)
+			return fmt.Errorf("Failed to fetch %s#%d: %w", repoName, pull.GetNumber(), err)
+		}
+		details[pull.GetNumber()] = detail
+	}
+	Kv.Set(fmt.Sprintf("%s-%s-pull-details", j.orgName, repoName), details, cache.DefaultExpiration)
+	return nil
+}
+
+// setReviewCacheByRepo fetches reviews of pull requests updated within the window
+// and review comments created within the window.
+func (j *Job) setReviewCacheByRepo(ctx context.Context, repoName string, pulls []*github.PullRequest) error {
+	since := time.Now().AddDate(0, 0, -config.ReviewConfig.Window)
+
+	// key is pull request number
+	reviews := make(map[int][]*github.PullRequestReview)
+	for _, pull := range pulls {
+		if pull.GetUpdatedAt().Before(since) {
+			continue
 		}
-		Kv.Set(fmt.Sprintf("%s-%s-pulls", j.orgName, repo.GetName()), pulls, cache.DefaultExpiration)
+		reviewOption := &github.ListOptions{PerPage: 100}
+		for {
+			rs, resp, err := j.client.PullRequests.ListReviews(ctx, j.orgName, repoName, pull.GetNumber(), reviewOption)
+			if _, ok := err.(*github.RateLimitError); ok {
+				return fmt.Errorf("Access Rate Limit: %w", err)
+			} else if err != nil {
+				return fmt.Errorf("Failed to fetch %s#%d reviews: %w", repoName, pull.GetNumber(), err)
+			}
+			reviews[pull.GetNumber()] = append(reviews[pull.GetNumber()], rs...)
+			if resp.NextPage == 0 {
+				break
+			}
+			reviewOption.Page = resp.NextPage
+		}
+	}
+	Kv.Set(fmt.Sprintf("%s-%s-reviews", j.orgName, repoName), reviews, cache.DefaultExpiration)
 
-		issues := make([]*github.Issue, 0)
-		allIssue, _, err := j.client.Issues.ListByRepo(ctx, j.orgName, repo.GetName(), issueListOption)
+	// pull request number 0 means all pull requests in the repository
+	commentOption := &github.PullRequestListCommentsOptions{
+		Sort:        "created",
+		Direction:   "desc",
+		Since:       since,
+		ListOptions: github.ListOptions{PerPage: 100},
+	}
+	var comments []*github.PullRequestComment
+	for {
+		cs, resp, err := j.client.PullRequests.ListComments(ctx, j.orgName, repoName, 0, commentOption)
 		if _, ok := err.(*github.RateLimitError); ok {
 			return fmt.Errorf("Access Rate Limit: %w", err)
 		} else if err != nil {
-			return fmt.Errorf("Failed to fetch %s issues: %w", repo.GetName(), err)
+			return fmt.Errorf("Failed to fetch %s review comments: %w", repoName, err)
+		}
+		comments = append(comments, cs...)
+		if resp.NextPage == 0 {
+			break
+		}
+		commentOption.Page = resp.NextPage
+	}
+	Kv.Set(fmt.Sprintf("%s-%s-review-comments", j.orgName, repoName), comments, cache.DefaultExpiration)
+	return nil
+}
+
+// setDORACacheByRepo fetches deployments (or releases) within the window
+// and links merged pull requests to the first deployment which contains them.
+func (j *Job) setDORACacheByRepo(ctx context.Context, repoName string, pulls []*github.PullRequest) error {
+	since := time.Now().AddDate(0, 0, -config.DORAConfig.Window)
+
+	deployments, err := j.listDeployments(ctx, repoName, since)
+	if err != nil {
+		return err
+	}
+	if len(deployments) == 0 {
+		// fallback to releases if the repository does not use deployments API
+		deployments, err = j.listReleases(ctx, repoName, since)
+		if err != nil {
+			return err
 		}
-		// filter by issues not pull requests
-		for _, issue := range allIssue {
-			if !issue.IsPullRequest() {
-				issues = append(issues, issue)
+	}
+	// oldest first to find the first deployment after merge
+	sort.Slice(deployments, func(a, b int) bool {
+		return deployments[a].CreatedAt.Before(deployments[b].CreatedAt)
+	})
+	Kv.Set(fmt.Sprintf("%s-%s-deployments", j.orgName, repoName), deployments, cache.DefaultExpiration)
+
+	// a delivered pull request never changes, so reuse the previous result
+	// not to call compare API again.
+	delivered := make(map[int]*Delivery)
+	if di, found := Kv.Get(fmt.Sprintf("%s-%s-deliveries", j.orgName, repoName)); found {
+		if prev, ok := di.([]*Delivery); ok {
+			for _, d := range prev {
+				delivered[d.Number] = d
 			}
 		}
-		Kv.Set(fmt.Sprintf("%s-%s-issues", j.orgName, repo.GetName()), issues, cache.DefaultExpiration)
 	}
+
+	deliveries := make([]*Delivery, 0)
+	for _, pull := range pulls {
+		mergedAt := pull.GetMergedAt()
+		if mergedAt.IsZero() || mergedAt.Before(since) {
+			continue
+		}
+		if d, ok := delivered[pull.GetNumber()]; ok {
+			deliveries = append(deliveries, d)
+			continue
+		}
+		d, err := j.findDelivery(ctx, repoName, pull, deployments)
+		if err != nil {
+			return err
+		}
+		if d != nil {
+			deliveries = append(deliveries, d)
+		}
+	}
+	Kv.Set(fmt.Sprintf("%s-%s-deliveries", j.orgName, repoName), deliveries, cache.DefaultExpiration)
 	return nil
 }
+
+func (j *Job) listDeployments(ctx context.Context, repoName string, since time.Time) ([]*Deployment, error) {
+	opt := &github.DeploymentsListOptions{
+		Environment: config.DORAConfig.Environment,
+		ListOptions: github.ListOptions{PerPage: 100},
+	}
+	deployments := make([]*Deployment, 0)
+	for {
+		// deployments are returned newest first
+		ds, resp, err := j.client.Repositories.ListDeployments(ctx, j.orgName, repoName, opt)
+		if _, ok := err.(*github.RateLimitError); ok {
+			return nil, fmt.Errorf("Access Rate Limit: %w", err)
+		} else if err != nil {
+			return nil, fmt.Errorf("Failed to fetch %s deployments: %w", repoName, err)
+		}
+		for _, d := range ds {
+			if d.GetCreatedAt().Before(since) {
+				return deployments, nil
+			}
+			// statuses are returned newest first, so the first one is the latest
+			statuses, _, err := j.client.Repositories.ListDeploymentStatuses(ctx, j.orgName, repoName, d.GetID(), &github.ListOptions{PerPage: 1})
+			if _, ok := err.(*github.RateLimitError); ok {
+				return nil, fmt.Errorf("Access Rate Limit: %w", err)
+			} else if err != nil {
+				return nil, fmt.Errorf("Failed to fetch %s deployment statuses: %w", repoName, err)
+			}
+			state := "pending"
+			if len(statuses) > 0 {
+				state = statuses[0].GetState()
+			}
+			deployments = append(deployments, &Deployment{
+				Source:    "deployment",
+				Ref:       d.GetRef(),
+				SHA:       d.GetSHA(),
+				CreatedAt: d.GetCreatedAt().Time,
+				State:     state,
+			})
+		}
+		if resp.NextPage == 0 {
+			return deployments, nil
+		}
+		opt.Page = resp.NextPage
+	}
+}
+
+func (j *Job) listReleases(ctx context.Context, repoName string, since time.Time) ([]*Deployment, error) {
+	releases, _, err := j.client.Repositories.ListReleases(ctx, j.orgName, repoName, &github.ListOptions{PerPage: 100})
+	if _, ok := err.(*github.RateLimitError); ok {
+		return nil, fmt.Errorf("Access Rate Limit: %w", err)
+	} else if err != nil {
+		return nil, fmt.Errorf("Failed to fetch %s releases: %w", repoName, err)
+	}
+	deployments := make([]*Deployment, 0)
+	for _, r := range releases {
+		if r.GetDraft() || r.GetPublishedAt().Before(since) {
+			continue
+		}
+		deployments = append(deployments, newDeploymentFromRelease(r))
+	}
+	return deployments, nil
+}
+
+// maxDeliveryCandidates limits compare API calls for each pull request
+const maxDeliveryCandidates = 5
+
+// findDelivery returns the first succeeded deployment which contains the merge commit.
+// deployments must be sorted oldest first. It returns nil if not deployed yet.
+func (j *Job) findDelivery(ctx context.Context, repoName string, pull *github.PullRequest, deployments []*Deployment) (*Delivery, error) {
+	candidates := 0
+	for _, d := range deployments {
+		if !d.Succeeded() || d.CreatedAt.Before(pull.GetMergedAt()) {
+			continue
+		}
+		if candidates >= maxDeliveryCandidates {
+			return nil, nil
+		}
+		candidates++
+
+		head := d.SHA
+		if d.Source == "release" {
+			head = d.Ref
+		}
+		comp, _, err := j.client.Repositories.CompareCommits(ctx, j.orgName, repoName, pull.GetMergeCommitSHA(), head)
+		if _, ok := err.(*github.RateLimitError); ok {
+			return nil, fmt.Errorf("Access Rate Limit: %w", err)
+		} else if err != nil {
+			return nil, fmt.Errorf("Failed to compare %s commits: %w", repoName, err)
+		}
+		// head contains base if it is ahead of or identical to base
+		if status := comp.GetStatus(); status == "ahead" || status == "identical" {
+			return &Delivery{
+				Number:     pull.GetNumber(),
+				URL:        pull.GetHTMLURL(),
+				MergedAt:   pull.GetMergedAt(),
+				DeployedAt: d.CreatedAt,
+			}, nil
+		}
+	}
+	return nil, nil
+}
+
+// repoFailure is a repository failed in the last execution
+type repoFailure struct {
+	// label is the value of repository name labels, which is a pseudonym if redacted
+	label  string
+	reason string
+}
+
+// recordRepoErrors replaces repo fetch errors of the org with the ones in the last execution
+func (j *Job) recordRepoErrors(errs RepoErrors) {
+	for _, f := range j.failedRepos {
+		repoFetchErrors.DeleteLabelValues(j.orgName, f.label, f.reason)
+	}
+	j.failedRepos = make(map[string]repoFailure, len(errs))
+	if len(errs) == 0 {
+		return
+	}
+	labels := j.repoLabels()
+	for repoName, err := range errs {
+		reason := repoErrorReason(err)
+		log.Warnf("failed to fetch %s/%s (%s): %v", j.orgName, repoName, reason, err)
+		label, ok := labels[repoName]
+		if !ok {
+			// repositories not cached are labeled as public ones
+			label = privacy.repoRef(&github.Repository{
+				Name:     github.String(repoName),
+				FullName: github.String(j.orgName + "/" + repoName),
+			}).label
+		}
+		j.failedRepos[repoName] = repoFailure{label, reason}
+		repoFetchErrors.WithLabelValues(j.orgName, label, reason).Set(1)
+	}
+}
+
+// repoLabels returns repository name labels of the cached repositories keyed by name
+func (j *Job) repoLabels() map[string]string {
+	ri, _ := Kv.Get(fmt.Sprintf("%s-repos", j.orgName))
+	repos, _ := ri.([]*github.Repository)
+	labels := make(map[string]string, len(repos))
+	for _, repo := range repos {
+		labels[repo.GetName()] = privacy.repoRef(repo).label
+	}
+	return labels
+}
+
+// failedRepoReasons returns reasons of the failed repositories keyed by repository name label
+func (j *Job) failedRepoReasons() map[string]string {
+	reasons := make(map[string]string, len(j.failedRepos))
+	for _, f := range j.failedRepos {
+		reasons[f.label] = f.reason
+	}
+	return reasons
+}
+
+// repoErrorReason classifies the error into a metric label value
+func repoErrorReason(err error) string {
+	var (
+		rateLimitErr      *github.RateLimitError
+		abuseRateLimitErr *github.AbuseRateLimitError
+		errResp           *github.ErrorResponse
+		netErr            net.Error
+	)
+	switch {
+	case errors.As(err, &rateLimitErr), errors.As(err, &abuseRateLimitErr):
+		return "rate_limit"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.As(err, &errResp) && errResp.Response != nil:
+		switch code := errResp.Response.StatusCode; {
+		case code == http.StatusNotFound:
+			return "not_found"
+		case code == http.StatusForbidden:
+			return "forbidden"
+		case code == http.StatusUnavailableForLegalReasons:
+			return "unavailable_for_legal_reasons"
+		case code >= 500:
+			return "server_error"
+		default:
+			return strconv.Itoa(code)
+		}
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return "timeout"
+	case errors.As(err, &netErr):
+		return "network"
+	default:
+		return "unknown"
+	}
+}
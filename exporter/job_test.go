@@ -0,0 +1,190 @@
+package exporter
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/go-github/v28/github"
+	"github.com/patrickmn/go-cache"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/ko-da-k/github-developer-exporter/config"
+)
+
+func TestSetCacheByRepo(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		running int
+		maxRun  int
+	)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		running++
+		if running > maxRun {
+			maxRun = running
+		}
+		mu.Unlock()
+		defer func() {
+			mu.Lock()
+			running--
+			mu.Unlock()
+		}()
+
+		if strings.HasPrefix(r.URL.Path, "/repos/ko-da-k/deleted/") {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"message":"Not Found"}`)
+			return
+		}
+		fmt.Fprint(w, `[]`)
+	})
+	client, teardown := newTestClient(t, mux)
+	defer teardown()
+
+	repos := []*github.Repository{{Name: github.String("deleted")}}
+	for i := 0; i < 10; i++ {
+		repos = append(repos, &github.Repository{Name: github.String(fmt.Sprintf("repo%d", i))})
+	}
+	Kv.Set("ko-da-k-repos", repos, cache.DefaultExpiration)
+	defer Kv.Flush()
+
+	j := NewJob(client, "ko-da-k")
+	err := j.setCacheByRepo(context.Background(), nil)
+
+	errs, ok := err.(RepoErrors)
+	if !ok {
+		t.Fatalf("unexpected error: got %v want RepoErrors", err)
+	}
+	if _, ok := errs["deleted"]; !ok || len(errs) != 1 {
+		t.Errorf("unexpected failed repositories: got %v want deleted", errs)
+	}
+	if _, found := Kv.Get("ko-da-k-repo9-pulls"); !found {
+		t.Errorf("repositories after the failed one should be cached")
+	}
+	if maxRun > config.GitHubConfig.RepoConcurrency {
+		t.Errorf("too many concurrent requests: got %v want <= %v", maxRun, config.GitHubConfig.RepoConcurrency)
+	}
+}
+
+func TestRecordRepoErrors(t *testing.T) {
+	notFound := &github.ErrorResponse{Response: &http.Response{StatusCode: http.StatusNotFound}}
+	legal := &github.ErrorResponse{Response: &http.Response{StatusCode: http.StatusUnavailableForLegalReasons}}
+	j := NewJob(nil, "ko-da-k")
+
+	j.recordRepoErrors(RepoErrors{
+		"deleted": fmt.Errorf("Failed to fetch deleted pulls: %w", notFound),
+		"blocked": fmt.Errorf("Failed to fetch blocked pulls: %w", legal),
+		"slow":    context.DeadlineExceeded,
+	})
+	expected := map[string]string{
+		"deleted": "not_found",
+		"blocked": "unavailable_for_legal_reasons",
+		"slow":    "timeout",
+	}
+	for repoName, reason := range expected {
+		if got := testutil.ToFloat64(repoFetchErrors.WithLabelValues("ko-da-k", repoName, reason)); got != 1 {
+			t.Errorf("%s: unexpected repo fetch errors: got %v want %v", repoName, got, 1)
+		}
+	}
+
+	// errors of the previous execution are removed
+	j.recordRepoErrors(nil)
+	ch := make(chan prometheus.Metric, len(expected))
+	repoFetchErrors.Collect(ch)
+	close(ch)
+	if got := len(ch); got != 0 {
+		t.Errorf("unexpected repo fetch errors: got %v want %v", got, 0)
+	}
+}
+
+func TestExecuteRetriesFailedRepos(t *testing.T) {
+	polling := config.GitHubConfig.EventsPolling
+	config.GitHubConfig.EventsPolling = true
+	defer func() { config.GitHubConfig.EventsPolling = polling }()
+
+	var (
+		mu      sync.Mutex
+		fetched = map[string]int{}
+	)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/orgs/ko-da-k", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"login":"ko-da-k"}`)
+	})
+	mux.HandleFunc("/orgs/ko-da-k/repos", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[{"name":"hoge"},{"name":"deleted"}]`)
+	})
+	mux.HandleFunc("/orgs/ko-da-k/teams", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[]`)
+	})
+	mux.HandleFunc("/orgs/ko-da-k/events", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"1"`)
+		fmt.Fprint(w, `[{"id":"1","repo":{"name":"ko-da-k/hoge"}}]`)
+	})
+	mux.HandleFunc("/repos/", func(w http.ResponseWriter, r *http.Request) {
+		repoName := strings.Split(r.URL.Path, "/")[3]
+		if strings.HasSuffix(r.URL.Path, "/pulls") {
+			mu.Lock()
+			fetched[repoName]++
+			mu.Unlock()
+		}
+		if repoName == "deleted" {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"message":"Not Found"}`)
+			return
+		}
+		fmt.Fprint(w, `[]`)
+	})
+	client, teardown := newTestClient(t, mux)
+	defer teardown()
+	defer Kv.Flush()
+
+	j := NewJob(client, "ko-da-k")
+	if err := j.Execute(context.Background()); err == nil {
+		t.Fatalf("deleted repository should fail")
+	}
+	if j.lastEventID == "" || j.eventsETag == "" {
+		t.Errorf("events cursor should be kept: got id %q etag %q", j.lastEventID, j.eventsETag)
+	}
+
+	// no new events, so only the failed repository is fetched again
+	j.Execute(context.Background())
+	if fetched["hoge"] != 1 || fetched["deleted"] != 2 {
+		t.Errorf("unexpected fetched repositories: got %v want hoge:1 deleted:2", fetched)
+	}
+	if j.lastEventID == "" || j.eventsETag == "" {
+		t.Errorf("events cursor should be kept: got id %q etag %q", j.lastEventID, j.eventsETag)
+	}
+}
+
+func TestRecordRepoErrorsRedacted(t *testing.T) {
+	defaultPrivacy := privacy
+	defer func() { privacy = defaultPrivacy }()
+	privacy, _ = NewPrivacyPolicy("", "", "", "key", "", true)
+	secret := &github.Repository{Name: github.String("secret"), FullName: github.String("ko-da-k/secret"), Private: github.Bool(true)}
+	Kv.Set("ko-da-k-repos", []*github.Repository{secret}, cache.DefaultExpiration)
+	defer Kv.Flush()
+	j := NewJob(nil, "ko-da-k")
+
+	j.recordRepoErrors(RepoErrors{"secret": context.DeadlineExceeded})
+	defer j.recordRepoErrors(nil)
+	label := privacy.repoRef(secret).label
+	if got := testutil.ToFloat64(repoFetchErrors.WithLabelValues("ko-da-k", label, "timeout")); got != 1 {
+		t.Errorf("unexpected repo fetch errors of redacted repository: got %v want %v", got, 1)
+	}
+	if reasons := j.failedRepoReasons(); len(reasons) != 1 || reasons[label] != "timeout" {
+		t.Errorf("unexpected failed repositories: got %v", reasons)
+	}
+	// failed repositories are retried by name
+	if _, ok := j.failedRepos["secret"]; !ok {
+		t.Errorf("failed repository should be keyed by name: got %v", j.failedRepos)
+	}
+}
@@ -0,0 +1,96 @@
+package exporter
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	promBridge "go.opentelemetry.io/contrib/bridges/prometheus"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/ko-da-k/github-developer-exporter/config"
+)
+
+const instrumentationName = "github.com/ko-da-k/github-developer-exporter/exporter"
+
+// tracer uses the global tracer provider, which is no-op unless OTEL_TRACES_EXPORTER=otlp
+var tracer = otel.Tracer(instrumentationName)
+
+// SetupOpenTelemetry starts OTLP/HTTP exporters selected by config.
+// Metrics are read from the gatherer by Prometheus bridge,
+// so metric names and attribute names are the same as /metrics.
+// The returned function flushes and stops the exporters.
+func SetupOpenTelemetry(ctx context.Context, gatherer prometheus.Gatherer) (func(context.Context) error, error) {
+	res, err := resource.New(ctx,
+		resource.WithAttributes(attribute.String("service.name", "github-developer-exporter")),
+		// OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES override the above
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var shutdowns []func(context.Context) error
+	shutdown := func(ctx context.Context) error {
+		var errs []error
+		for _, f := range shutdowns {
+			errs = append(errs, f(ctx))
+		}
+		return errors.Join(errs...)
+	}
+
+	if config.OTelConfig.MetricsExporter == "otlp" {
+		exp, err := otlpmetrichttp.New(ctx)
+		if err != nil {
+			return nil, err
+		}
+		// OTEL_METRIC_EXPORT_INTERVAL is read by the reader
+		reader := sdkmetric.NewPeriodicReader(exp,
+			sdkmetric.WithProducer(promBridge.NewMetricProducer(promBridge.WithGatherer(gatherer))),
+		)
+		mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader), sdkmetric.WithResource(res))
+		shutdowns = append(shutdowns, mp.Shutdown)
+	}
+
+	if config.OTelConfig.TracesExporter == "otlp" {
+		exp, err := otlptracehttp.New(ctx)
+		if err != nil {
+			shutdown(ctx)
+			return nil, err
+		}
+		tp := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exp), sdktrace.WithResource(res))
+		otel.SetTracerProvider(tp)
+		shutdowns = append(shutdowns, tp.Shutdown)
+	}
+	return shutdown, nil
+}
+
+// newTracingTransport traces each GitHub API call including rate limit waits, retries and cache.
+// Trace context is not propagated to GitHub.
+func newTracingTransport(base http.RoundTripper) http.RoundTripper {
+	return otelhttp.NewTransport(base,
+		otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+			return "GitHub API " + r.Method
+		}),
+	)
+}
+
+// endSpan records the error if any and ends the span
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
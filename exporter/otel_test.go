@@ -0,0 +1,79 @@
+package exporter
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+
+	"github.com/google/go-github/v28/github"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/ko-da-k/github-developer-exporter/config"
+)
+
+func TestJobExecuteTrace(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+	client := github.NewClient(&http.Client{Transport: newTracingTransport(http.DefaultTransport)})
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+
+	if err := NewJob(client, "ko-da-k").Execute(context.Background()); err == nil {
+		t.Fatalf("missing org should be an error")
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("unexpected spans: got %v want %v", len(spans), 2)
+	}
+	api, job := spans[0], spans[1]
+	if api.Name() != "GitHub API GET" || job.Name() != "Job.Execute" {
+		t.Errorf("unexpected span names: got %v, %v", api.Name(), job.Name())
+	}
+	if api.Parent().SpanID() != job.SpanContext().SpanID() {
+		t.Errorf("GitHub API call should be a child of the job")
+	}
+	if job.Status().Code != codes.Error {
+		t.Errorf("unexpected job status: got %v want %v", job.Status().Code, codes.Error)
+	}
+}
+
+func TestSetupOpenTelemetryMetrics(t *testing.T) {
+	received := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case received <- r.URL.Path:
+		default:
+		}
+	}))
+	defer server.Close()
+	os.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", server.URL)
+	defer os.Unsetenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+	config.OTelConfig.MetricsExporter = "otlp"
+	defer func() { config.OTelConfig.MetricsExporter = "none" }()
+
+	shutdown, err := SetupOpenTelemetry(context.Background(), newTestRegistry())
+	if err != nil {
+		t.Fatalf("%+v\n", err)
+	}
+	// shutdown flushes metrics
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatalf("%+v\n", err)
+	}
+	select {
+	case path := <-received:
+		if path != "/v1/metrics" {
+			t.Errorf("unexpected path: got %v want %v", path, "/v1/metrics")
+		}
+	default:
+		t.Errorf("metrics were not exported")
+	}
+}
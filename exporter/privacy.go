@@ -0,0 +1,196 @@
+package exporter
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/google/go-github/v28/github"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/ko-da-k/github-developer-exporter/config"
+)
+
+const (
+	privacyKeep     = "keep"
+	privacyDrop     = "drop"
+	privacyHash     = "hash"
+	privacyTruncate = "truncate"
+
+	// pseudonymLength is hex digits of hashed values
+	pseudonymLength = 16
+)
+
+// privacy is built from PrivacyConfig
+var privacy *PrivacyPolicy
+
+func init() {
+	var err error
+	privacy, err = NewPrivacyPolicy(
+		config.PrivacyConfig.Titles,
+		config.PrivacyConfig.Emails,
+		config.PrivacyConfig.Logins,
+		config.PrivacyConfig.HashKey,
+		config.PrivacyConfig.RedactRepos,
+		config.PrivacyConfig.RedactPrivateRepos,
+	)
+	if err != nil {
+		log.Fatalf("privacy config error: %+v", err)
+	}
+}
+
+// fieldPolicy is how label values of a field are exposed
+type fieldPolicy struct {
+	mode string
+	// length is runes kept by truncate
+	length int
+}
+
+// parseFieldPolicy parses "keep", "drop", "hash" or "truncate:<length>"
+func parseFieldPolicy(s string) (fieldPolicy, error) {
+	mode, arg, hasArg := strings.Cut(strings.TrimSpace(s), ":")
+	switch mode {
+	case "", privacyKeep:
+		return fieldPolicy{mode: privacyKeep}, nil
+	case privacyDrop, privacyHash:
+		if hasArg {
+			return fieldPolicy{}, fmt.Errorf("%s takes no argument: %q", mode, s)
+		}
+		return fieldPolicy{mode: mode}, nil
+	case privacyTruncate:
+		length, err := strconv.Atoi(arg)
+		if err != nil || length < 1 {
+			return fieldPolicy{}, fmt.Errorf("truncate requires a positive length: %q", s)
+		}
+		return fieldPolicy{mode: mode, length: length}, nil
+	}
+	return fieldPolicy{}, fmt.Errorf("unknown privacy mode %q", s)
+}
+
+// PrivacyPolicy drops, hashes or truncates label values of titles, emails and logins,
+// and redacts repositories.
+// Hashed values are HMAC of the key, so the same user has the same pseudonym in all metrics.
+type PrivacyPolicy struct {
+	titles fieldPolicy
+	emails fieldPolicy
+	logins fieldPolicy
+	key    []byte
+	// repos is nil if no repository is redacted by name
+	repos        *regexp.Regexp
+	privateRepos bool
+}
+
+// NewPrivacyPolicy constructor
+// redactRepos is a regular expression which must match a whole repository name.
+func NewPrivacyPolicy(titles, emails, logins, hashKey, redactRepos string, redactPrivateRepos bool) (*PrivacyPolicy, error) {
+	p := &PrivacyPolicy{key: []byte(hashKey), privateRepos: redactPrivateRepos}
+	var err error
+	for _, f := range []struct {
+		name   string
+		value  string
+		policy *fieldPolicy
+	}{
+		{"titles", titles, &p.titles},
+		{"emails", emails, &p.emails},
+		{"logins", logins, &p.logins},
+	} {
+		if *f.policy, err = parseFieldPolicy(f.value); err != nil {
+			return nil, fmt.Errorf("%s: %w", f.name, err)
+		}
+	}
+	if redactRepos != "" {
+		if p.repos, err = regexp.Compile("^(?:" + redactRepos + ")$"); err != nil {
+			return nil, fmt.Errorf("invalid redact repos pattern %q: %w", redactRepos, err)
+		}
+	}
+	// pseudonyms without the key can be guessed from the members and repositories of the organization
+	if hashKey == "" {
+		if p.titles.mode == privacyHash || p.emails.mode == privacyHash || p.logins.mode == privacyHash {
+			return nil, errors.New("hash requires a hash key")
+		}
+		if p.repos != nil || p.privateRepos {
+			return nil, errors.New("redacting repositories requires a hash key")
+		}
+	}
+	return p, nil
+}
+
+func (p *PrivacyPolicy) apply(f fieldPolicy, prefix, v string) string {
+	if v == "" {
+		return ""
+	}
+	switch f.mode {
+	case privacyDrop:
+		return ""
+	case privacyHash:
+		return p.pseudonym(prefix, v)
+	case privacyTruncate:
+		if r := []rune(v); len(r) > f.length {
+			return string(r[:f.length])
+		}
+	}
+	return v
+}
+
+// pseudonym returns the consistent ID of v which cannot be reversed without the key
+func (p *PrivacyPolicy) pseudonym(prefix, v string) string {
+	mac := hmac.New(sha256.New, p.key)
+	mac.Write([]byte(v))
+	return prefix + hex.EncodeToString(mac.Sum(nil))[:pseudonymLength]
+}
+
+// Title returns the label value of an issue or pull request title
+func (p *PrivacyPolicy) Title(title string) string {
+	return p.apply(p.titles, "title-", title)
+}
+
+// Email returns the label value of an email
+func (p *PrivacyPolicy) Email(email string) string {
+	return p.apply(p.emails, "email-", email)
+}
+
+// Login returns the label value of a user login
+func (p *PrivacyPolicy) Login(login string) string {
+	return p.apply(p.logins, "user-", login)
+}
+
+// Redacted reports whether metrics of the repository are redacted
+func (p *PrivacyPolicy) Redacted(repo *github.Repository) bool {
+	if p.privateRepos && repo.GetPrivate() {
+		return true
+	}
+	return p.repos != nil && p.repos.MatchString(repo.GetName())
+}
+
+// repoRef is a repository which metrics are set for
+type repoRef struct {
+	// name is used to look up the cache
+	name string
+	// label is the value of repository name labels, which is a pseudonym if redacted
+	label    string
+	redacted bool
+}
+
+func (p *PrivacyPolicy) repoRef(repo *github.Repository) repoRef {
+	ref := repoRef{name: repo.GetName(), label: repo.GetName()}
+	if p.Redacted(repo) {
+		// full name not to have the same pseudonym as a repository of another org
+		ref.label = p.pseudonym("repo-", repo.GetFullName())
+		ref.redacted = true
+	}
+	return ref
+}
+
+// text returns v unless the repository is redacted.
+// It is used for free text such as titles, labels, branches and URLs.
+func (r repoRef) text(v string) string {
+	if r.redacted {
+		return ""
+	}
+	return v
+}
@@ -0,0 +1,166 @@
+package exporter
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v28/github"
+	"github.com/patrickmn/go-cache"
+)
+
+func TestPrivacyPolicy(t *testing.T) {
+	p, err := NewPrivacyPolicy("truncate:5", "drop", "hash", "key", "secret-.*", false)
+	if err != nil {
+		t.Fatalf("%+v\n", err)
+	}
+	if got := p.Title("こんにちは世界"); got != "こんにちは" {
+		t.Errorf("unexpected title: got %v", got)
+	}
+	if got := p.Title("short"); got != "short" {
+		t.Errorf("unexpected title: got %v", got)
+	}
+	if got := p.Email("org@example.com"); got != "" {
+		t.Errorf("unexpected email: got %v", got)
+	}
+	alice := p.Login("alice")
+	if !strings.HasPrefix(alice, "user-") || len(alice) != len("user-")+pseudonymLength || strings.Contains(alice, "alice") {
+		t.Errorf("unexpected login: got %v", alice)
+	}
+	if p.Login("alice") != alice || p.Login("bob") == alice {
+		t.Errorf("pseudonyms should be consistent and distinct")
+	}
+	if p.Login("") != "" {
+		t.Errorf("empty login should be kept empty")
+	}
+	other, _ := NewPrivacyPolicy("", "", "hash", "another key", "", false)
+	if other.Login("alice") == alice {
+		t.Errorf("pseudonyms should depend on the key")
+	}
+
+	cases := []struct {
+		repo     *github.Repository
+		redacted bool
+	}{
+		{&github.Repository{Name: github.String("secret-plan")}, true},
+		{&github.Repository{Name: github.String("not-secret-plan")}, false},
+		{&github.Repository{Name: github.String("hoge"), Private: github.Bool(true)}, false},
+	}
+	for _, c := range cases {
+		if got := p.Redacted(c.repo); got != c.redacted {
+			t.Errorf("%s: got %v want %v", c.repo.GetName(), got, c.redacted)
+		}
+	}
+}
+
+func TestNewPrivacyPolicyInvalid(t *testing.T) {
+	cases := []struct {
+		titles        string
+		logins        string
+		key           string
+		repos         string
+		redactPrivate bool
+	}{
+		{"remove", "", "key", "", false},
+		{"truncate", "", "key", "", false},
+		{"truncate:0", "", "key", "", false},
+		{"hash:8", "", "key", "", false},
+		{"keep", "", "key", "(", false},
+		// pseudonyms require the key
+		{"hash", "", "", "", false},
+		{"", "hash", "", "", false},
+		{"", "", "", "secret", false},
+		{"", "", "", "", true},
+	}
+	for _, c := range cases {
+		if _, err := NewPrivacyPolicy(c.titles, "", c.logins, c.key, c.repos, c.redactPrivate); err == nil {
+			t.Errorf("%+v: error should be returned", c)
+		}
+	}
+	if _, err := NewPrivacyPolicy("drop", "truncate:3", "keep", "", "", false); err != nil {
+		t.Errorf("drop and truncate should not require the key: %v", err)
+	}
+}
+
+func TestCollectorPrivacy(t *testing.T) {
+	orgName := "privacy"
+	now := time.Now()
+	alice := &github.User{Login: github.String("alice")}
+	bob := &github.User{Login: github.String("bob")}
+	Kv.Set(orgName, &github.Organization{Login: github.String(orgName), Email: github.String("org@example.com")}, cache.DefaultExpiration)
+	Kv.Set(orgName+"-repos", []*github.Repository{
+		{Name: github.String("public"), FullName: github.String(orgName + "/public")},
+		{Name: github.String("private"), FullName: github.String(orgName + "/private"), Private: github.Bool(true)},
+	}, cache.DefaultExpiration)
+	for _, repo := range []string{"public", "private"} {
+		Kv.Set(orgName+"-"+repo+"-issues", []*github.Issue{
+			{Number: github.Int(1), State: github.String("open"), UpdatedAt: &now, Title: github.String("vulnerability"),
+				Assignee: alice, Assignees: []*github.User{alice, bob}},
+		}, cache.DefaultExpiration)
+		Kv.Set(orgName+"-"+repo+"-pulls", []*github.PullRequest{
+			{Number: github.Int(2), State: github.String("open"), UpdatedAt: &now, Title: github.String("fix vulnerability"),
+				User: alice, RequestedReviewers: []*github.User{bob}},
+			{Number: github.Int(3), State: github.String("open"), UpdatedAt: &now, User: bob},
+		}, cache.DefaultExpiration)
+	}
+	Kv.Set(orgName+"-private-workflow-runs", map[string]*WorkflowRun{
+		"build":  {Name: github.String("build"), HeadBranch: github.String("fix-cve"), Status: github.String("completed")},
+		"deploy": {Name: github.String("deploy"), HeadBranch: github.String("main"), Status: github.String("completed")},
+	}, cache.DefaultExpiration)
+	defer Kv.Flush()
+
+	defaultPrivacy := privacy
+	defer func() { privacy = defaultPrivacy }()
+	privacy, _ = NewPrivacyPolicy("hash", "drop", "drop", "key", "", true)
+	snapshots.Invalidate(orgName)
+	defer snapshots.Invalidate(orgName)
+
+	// gather fails if dropped logins make duplicated label values
+	families := gather(t, NewDevCollector([]*GitHubCollector{NewGitHubCollector(orgName)}))
+
+	labels := func(name string) []map[string]string {
+		var ret []map[string]string
+		for _, m := range families[name].GetMetric() {
+			l := make(map[string]string)
+			for _, pair := range m.GetLabel() {
+				l[pair.GetName()] = pair.GetValue()
+			}
+			ret = append(ret, l)
+		}
+		return ret
+	}
+	if email := labels("org_info")[0]["email"]; email != "" {
+		t.Errorf("email should be dropped: got %v", email)
+	}
+	for _, l := range labels("pull_request_info") {
+		if l["assignee"] != "" || l["reviewer"] != "" {
+			t.Errorf("logins should be dropped: got %v", l)
+		}
+		switch {
+		case l["repo_name"] == "public":
+			if l["title"] != "" && !strings.HasPrefix(l["title"], "title-") {
+				t.Errorf("title should be hashed: got %v", l["title"])
+			}
+		case strings.HasPrefix(l["repo_name"], "repo-"):
+			if l["title"] != "" {
+				t.Errorf("title of redacted repo should be empty: got %v", l["title"])
+			}
+		default:
+			t.Errorf("private repo name should be redacted: got %v", l["repo_name"])
+		}
+	}
+	if n := len(labels("pull_request_info")); n != 3 {
+		t.Errorf("pull requests of redacted repo should be aggregated: got %v series", n)
+	}
+	if runs := families["workflow_run_info"].GetMetric(); len(runs) != 1 || runs[0].GetGauge().GetValue() != 2 {
+		t.Errorf("workflow runs of redacted repo should be aggregated: got %v", runs)
+	}
+	for _, l := range labels("user_open_pull_requests_count") {
+		if l["login"] != "" {
+			t.Errorf("login should be dropped: got %v", l)
+		}
+	}
+	if n := len(labels("user_open_pull_requests_count")); n != 1 {
+		t.Errorf("users should be aggregated: got %v series", n)
+	}
+}
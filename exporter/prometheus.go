@@ -2,10 +2,32 @@ package exporter
 
 import (
 	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/ko-da-k/github-developer-exporter/config"
 )
 
-func RecordMetrics(gs []*GitHubCollector) {
-	c := NewDevCollector(gs)
-	prometheus.MustRegister(c)
-	return
+// NewRegistry returns a registry owned by the exporter instead of the global one,
+// so that several registries can be built in a process, e.g. in tests.
+func NewRegistry(gs []*GitHubCollector) *prometheus.Registry {
+	reg := prometheus.NewRegistry()
+	if config.ServerConfig.RuntimeMetrics {
+		reg.MustRegister(
+			prometheus.NewGoCollector(),
+			prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
+		)
+	}
+	reg.MustRegister(
+		NewDevCollector(gs),
+		pullRequestEventsTotal,
+		issueEventsTotal,
+		reviewSubmittedTotal,
+		httpCacheHitsTotal,
+		httpCacheMissesTotal,
+		httpCacheEvictionsTotal,
+		repoFetchErrors,
+		httpRetriesTotal,
+		httpRetryGiveUpsTotal,
+		remoteWritePushesTotal,
+	)
+	return reg
 }
@@ -0,0 +1,89 @@
+package exporter
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/ko-da-k/github-developer-exporter/config"
+)
+
+// rateLimit is shared by all jobs because they use the same token
+var rateLimit = newRateLimiter()
+
+// rateLimiter keeps the latest rate limit given by GitHub API response headers
+// and makes requests wait until reset if the remaining is less than the reserve.
+type rateLimiter struct {
+	mu        sync.RWMutex
+	limit     int
+	remaining int
+	reset     time.Time
+}
+
+func newRateLimiter() *rateLimiter {
+	// unknown until the first response
+	return &rateLimiter{remaining: -1}
+}
+
+// Observe updates the rate limit by X-RateLimit-* headers
+func (r *rateLimiter) Observe(header http.Header) {
+	remaining, err := strconv.Atoi(header.Get("X-RateLimit-Remaining"))
+	if err != nil {
+		// GitHub Enterprise may disable rate limit
+		return
+	}
+	limit, _ := strconv.Atoi(header.Get("X-RateLimit-Limit"))
+	reset, _ := strconv.ParseInt(header.Get("X-RateLimit-Reset"), 10, 64)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.limit = limit
+	r.remaining = remaining
+	r.reset = time.Unix(reset, 0)
+}
+
+// Wait blocks until reset if the remaining is less than the reserve
+func (r *rateLimiter) Wait(ctx context.Context) error {
+	r.mu.RLock()
+	remaining, reset := r.remaining, r.reset
+	r.mu.RUnlock()
+
+	if remaining < 0 || remaining > config.GitHubConfig.RateLimitReserve {
+		return nil
+	}
+	d := time.Until(reset)
+	if d <= 0 {
+		return nil
+	}
+	log.Warnf("rate limit remaining %d, wait %s until reset", remaining, d)
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// rateLimitTransport waits for the rate limit before each request and observes it after that
+type rateLimitTransport struct {
+	base    http.RoundTripper
+	limiter *rateLimiter
+}
+
+func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.limiter.Wait(req.Context()); err != nil {
+		return nil, err
+	}
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	t.limiter.Observe(resp.Header)
+	return resp, nil
+}
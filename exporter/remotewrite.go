@@ -0,0 +1,249 @@
+package exporter
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/ko-da-k/github-developer-exporter/config"
+)
+
+var remoteWritePushesTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "github_exporter_remote_write_pushes_total",
+		Help: "How many push requests to the remote write endpoint were sent after jobs.",
+	},
+	[]string{"result"},
+)
+
+// RemoteWriter pushes gathered metrics to Prometheus remote write endpoint
+type RemoteWriter struct {
+	url      string
+	gatherer prometheus.Gatherer
+	client   *http.Client
+	policy   retryPolicy
+	// maxSeries is series in a request
+	maxSeries int
+}
+
+func NewRemoteWriter(gatherer prometheus.Gatherer) *RemoteWriter {
+	return &RemoteWriter{
+		url:      config.RemoteWriteConfig.URL,
+		gatherer: gatherer,
+		client:   &http.Client{Timeout: config.RemoteWriteConfig.Timeout},
+		policy: retryPolicy{
+			maxAttempts: config.RemoteWriteConfig.RetryMaxAttempts,
+			baseDelay:   config.RemoteWriteConfig.RetryBaseDelay,
+			maxDelay:    config.RemoteWriteConfig.RetryMaxDelay,
+		},
+		maxSeries: config.RemoteWriteConfig.MaxSeriesPerRequest,
+	}
+}
+
+// Push sends all gathered series with the current timestamp in requests of up to maxSeries series.
+// A failed request does not stop the others, and the last error is returned.
+func (rw *RemoteWriter) Push(ctx context.Context) error {
+	mfs, err := rw.gatherer.Gather()
+	if err != nil {
+		// gathered families are still pushed like promhttp.ContinueOnError
+		log.Warnf("failed to gather some metrics for remote write: %v", err)
+	}
+	series := toTimeSeries(mfs, time.Now())
+
+	var lastErr error
+	for start := 0; start < len(series); start += rw.maxSeries {
+		end := start + rw.maxSeries
+		if end > len(series) {
+			end = len(series)
+		}
+		if err := rw.push(ctx, series[start:end]); err != nil {
+			lastErr = err
+			if ctx.Err() != nil {
+				break
+			}
+		}
+	}
+	return lastErr
+}
+
+// PushAfterJobs is the idle hook of the dispatcher which pushes metrics once after all jobs of a tick,
+// even if some of them failed, because org_up and last known good data of orgs are still exported.
+func (rw *RemoteWriter) PushAfterJobs(ctx context.Context) {
+	if err := rw.Push(ctx); err != nil {
+		log.Errorf("Failed to push metrics after jobs: %v", err)
+	}
+}
+
+// push sends a request of the series with retries
+func (rw *RemoteWriter) push(ctx context.Context, series []timeSeries) error {
+	body := snappy.Encode(nil, encodeWriteRequest(series))
+
+	for attempt := 1; ; attempt++ {
+		retryable, err := rw.send(ctx, body)
+		if err == nil {
+			remoteWritePushesTotal.WithLabelValues("success").Inc()
+			return nil
+		}
+		if !retryable || attempt >= rw.policy.maxAttempts || ctx.Err() != nil {
+			remoteWritePushesTotal.WithLabelValues("failure").Inc()
+			return err
+		}
+		delay := rw.policy.backoff(attempt)
+		log.Warnf("retry remote write in %s (attempt %d): %v", delay, attempt, err)
+		t := time.NewTimer(delay)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			remoteWritePushesTotal.WithLabelValues("failure").Inc()
+			return ctx.Err()
+		}
+	}
+}
+
+// send returns whether the error is retryable.
+// 4xx except 429 means the payload is rejected, so it is not retried.
+func (rw *RemoteWriter) send(ctx context.Context, body []byte) (bool, error) {
+	req, err := http.NewRequest(http.MethodPost, rw.url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("User-Agent", "github-developer-exporter")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	if token := config.RemoteWriteConfig.BearerToken; token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	} else if config.RemoteWriteConfig.Username != "" {
+		req.SetBasicAuth(config.RemoteWriteConfig.Username, config.RemoteWriteConfig.Password)
+	}
+
+	resp, err := rw.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 == 2 {
+		// drain to reuse the connection
+		io.Copy(ioutil.Discard, resp.Body)
+		return false, nil
+	}
+	msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+	err = fmt.Errorf("remote write returned %s: %s", resp.Status, bytes.TrimSpace(msg))
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode/100 == 5, err
+}
+
+type label struct {
+	name  string
+	value string
+}
+
+type timeSeries struct {
+	labels    []label
+	value     float64
+	timestamp int64
+}
+
+// toTimeSeries flattens metric families into series as Prometheus does on scrape
+func toTimeSeries(mfs []*dto.MetricFamily, now time.Time) []timeSeries {
+	ts := now.UnixNano() / int64(time.Millisecond)
+	var series []timeSeries
+	for _, mf := range mfs {
+		name := mf.GetName()
+		for _, m := range mf.GetMetric() {
+			add := func(suffix string, value float64, extra ...label) {
+				labels := make([]label, 0, len(m.GetLabel())+len(extra)+1)
+				labels = append(labels, label{"__name__", name + suffix})
+				for _, l := range m.GetLabel() {
+					labels = append(labels, label{l.GetName(), l.GetValue()})
+				}
+				labels = append(labels, extra...)
+				sort.Slice(labels, func(i, j int) bool { return labels[i].name < labels[j].name })
+				series = append(series, timeSeries{labels, value, ts})
+			}
+
+			switch mf.GetType() {
+			case dto.MetricType_COUNTER:
+				add("", m.GetCounter().GetValue())
+			case dto.MetricType_GAUGE:
+				add("", m.GetGauge().GetValue())
+			case dto.MetricType_UNTYPED:
+				add("", m.GetUntyped().GetValue())
+			case dto.MetricType_SUMMARY:
+				s := m.GetSummary()
+				for _, q := range s.GetQuantile() {
+					add("", q.GetValue(), label{"quantile", formatFloat(q.GetQuantile())})
+				}
+				add("_sum", s.GetSampleSum())
+				add("_count", float64(s.GetSampleCount()))
+			case dto.MetricType_HISTOGRAM:
+				h := m.GetHistogram()
+				inf := false
+				for _, b := range h.GetBucket() {
+					inf = inf || math.IsInf(b.GetUpperBound(), 1)
+					add("_bucket", float64(b.GetCumulativeCount()), label{"le", formatFloat(b.GetUpperBound())})
+				}
+				if !inf {
+					add("_bucket", float64(h.GetSampleCount()), label{"le", "+Inf"})
+				}
+				add("_sum", h.GetSampleSum())
+				add("_count", float64(h.GetSampleCount()))
+			}
+		}
+	}
+	return series
+}
+
+func formatFloat(f float64) string {
+	if math.IsInf(f, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// encodeWriteRequest encodes series as prometheus.WriteRequest protobuf message
+//
+//	message WriteRequest { repeated TimeSeries timeseries = 1; }
+//	message TimeSeries { repeated Label labels = 1; repeated Sample samples = 2; }
+//	message Label { string name = 1; string value = 2; }
+//	message Sample { double value = 1; int64 timestamp = 2; }
+func encodeWriteRequest(series []timeSeries) []byte {
+	var req []byte
+	for _, s := range series {
+		var ts []byte
+		for _, l := range s.labels {
+			var lb []byte
+			lb = protowire.AppendTag(lb, 1, protowire.BytesType)
+			lb = protowire.AppendString(lb, l.name)
+			lb = protowire.AppendTag(lb, 2, protowire.BytesType)
+			lb = protowire.AppendString(lb, l.value)
+			ts = protowire.AppendTag(ts, 1, protowire.BytesType)
+			ts = protowire.AppendBytes(ts, lb)
+		}
+		var sample []byte
+		sample = protowire.AppendTag(sample, 1, protowire.Fixed64Type)
+		sample = protowire.AppendFixed64(sample, math.Float64bits(s.value))
+		sample = protowire.AppendTag(sample, 2, protowire.VarintType)
+		sample = protowire.AppendVarint(sample, uint64(s.timestamp))
+		ts = protowire.AppendTag(ts, 2, protowire.BytesType)
+		ts = protowire.AppendBytes(ts, sample)
+
+		req = protowire.AppendTag(req, 1, protowire.BytesType)
+		req = protowire.AppendBytes(req, ts)
+	}
+	return req
+}
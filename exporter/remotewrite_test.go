@@ -0,0 +1,293 @@
+package exporter
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/ko-da-k/github-developer-exporter/config"
+)
+
+// decodeWriteRequest decodes prometheus.WriteRequest into series keyed by labels
+func decodeWriteRequest(b []byte) (map[string]float64, error) {
+	series := make(map[string]float64)
+	err := consumeMessages(b, func(num protowire.Number, ts []byte) error {
+		if num != 1 {
+			return fmt.Errorf("unexpected field %d of WriteRequest", num)
+		}
+		key := ""
+		value := math.NaN()
+		err := consumeMessages(ts, func(num protowire.Number, m []byte) error {
+			switch num {
+			case 1:
+				var name, v string
+				err := consumeMessages(m, func(num protowire.Number, s []byte) error {
+					if num == 1 {
+						name = string(s)
+					} else {
+						v = string(s)
+					}
+					return nil
+				})
+				key += fmt.Sprintf("%s=%q,", name, v)
+				return err
+			case 2:
+				tag, _, n := protowire.ConsumeTag(m)
+				if tag != 1 || n < 0 {
+					return fmt.Errorf("unexpected sample")
+				}
+				bits, _ := protowire.ConsumeFixed64(m[n:])
+				value = math.Float64frombits(bits)
+				return nil
+			}
+			return fmt.Errorf("unexpected field %d of TimeSeries", num)
+		})
+		series[key] = value
+		return err
+	})
+	return series, err
+}
+
+// consumeMessages calls f with bytes fields of the message and skips the others
+func consumeMessages(b []byte, f func(protowire.Number, []byte) error) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+		if typ != protowire.BytesType {
+			n = protowire.ConsumeFieldValue(num, typ, b)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			b = b[n:]
+			continue
+		}
+		v, n := protowire.ConsumeBytes(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		if err := f(num, v); err != nil {
+			return err
+		}
+		b = b[n:]
+	}
+	return nil
+}
+
+func newTestRegistry() *prometheus.Registry {
+	reg := prometheus.NewRegistry()
+	g := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "test_gauge", Help: "test"}, []string{"org"})
+	g.WithLabelValues("ko-da-k").Set(3)
+	h := prometheus.NewHistogram(prometheus.HistogramOpts{Name: "test_histogram", Help: "test", Buckets: []float64{1}})
+	h.Observe(0.5)
+	h.Observe(2)
+	reg.MustRegister(g, h)
+	return reg
+}
+
+func TestRemoteWriterPush(t *testing.T) {
+	config.RemoteWriteConfig.BearerToken = "token"
+	defer func() { config.RemoteWriteConfig.BearerToken = "" }()
+
+	var received map[string]float64
+	attempts := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer token" || r.Header.Get("Content-Encoding") != "snappy" {
+			t.Errorf("unexpected headers: %v", r.Header)
+		}
+		compressed, _ := ioutil.ReadAll(r.Body)
+		b, err := snappy.Decode(nil, compressed)
+		if err != nil {
+			t.Errorf("%+v", err)
+		}
+		if received, err = decodeWriteRequest(b); err != nil {
+			t.Errorf("%+v", err)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	rw := NewRemoteWriter(newTestRegistry())
+	rw.url = ts.URL
+	rw.policy = retryPolicy{maxAttempts: 2, baseDelay: time.Millisecond, maxDelay: time.Millisecond}
+	if err := rw.Push(context.Background()); err != nil {
+		t.Fatalf("%+v\n", err)
+	}
+
+	if attempts != 2 {
+		t.Errorf("unexpected attempts: got %v want %v", attempts, 2)
+	}
+	expected := map[string]float64{
+		`__name__="test_gauge",org="ko-da-k",`:        3,
+		`__name__="test_histogram_bucket",le="1",`:    1,
+		`__name__="test_histogram_bucket",le="+Inf",`: 2,
+		`__name__="test_histogram_sum",`:              2.5,
+		`__name__="test_histogram_count",`:            2,
+	}
+	for key, want := range expected {
+		if got, ok := received[key]; !ok || got != want {
+			t.Errorf("%s: got %v want %v", key, got, want)
+		}
+	}
+}
+
+func TestRemoteWriterPushRejected(t *testing.T) {
+	attempts := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer ts.Close()
+
+	rw := NewRemoteWriter(newTestRegistry())
+	rw.url = ts.URL
+	rw.policy = retryPolicy{maxAttempts: 3, baseDelay: time.Millisecond, maxDelay: time.Millisecond}
+	if err := rw.Push(context.Background()); err == nil {
+		t.Errorf("400 should be an error")
+	}
+	// rejected payload is not retried
+	if attempts != 1 {
+		t.Errorf("unexpected attempts: got %v want %v", attempts, 1)
+	}
+}
+
+func TestRemoteWriterPushSplit(t *testing.T) {
+	received := make(map[string]float64)
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		compressed, _ := ioutil.ReadAll(r.Body)
+		b, err := snappy.Decode(nil, compressed)
+		if err != nil {
+			t.Errorf("%+v", err)
+		}
+		series, err := decodeWriteRequest(b)
+		if err != nil {
+			t.Errorf("%+v", err)
+		}
+		if len(series) > 2 {
+			t.Errorf("too many series in a request: got %v want <= %v", len(series), 2)
+		}
+		for k, v := range series {
+			received[k] = v
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	rw := NewRemoteWriter(newTestRegistry())
+	rw.url = ts.URL
+	rw.maxSeries = 2
+	if err := rw.Push(context.Background()); err != nil {
+		t.Fatalf("%+v\n", err)
+	}
+	// a gauge and 4 series of a histogram
+	if requests != 3 || len(received) != 5 {
+		t.Errorf("unexpected requests: got %v requests of %v series want 3 of 5", requests, len(received))
+	}
+}
+
+// countingWorker counts jobs instead of executing them
+type countingWorker struct {
+	mu   sync.Mutex
+	jobs int
+}
+
+func (w *countingWorker) Work(ctx context.Context, job *Job) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.jobs++
+}
+
+func TestDispatcherOnIdle(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	w := &countingWorker{}
+	d := NewDispatcher(w)
+	var (
+		mu    sync.Mutex
+		idles []int
+	)
+	d.OnIdle(func(context.Context) {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		mu.Lock()
+		defer mu.Unlock()
+		idles = append(idles, w.jobs)
+	})
+	// jobs of a tick are queued before they start
+	for _, org := range []string{"ko-da-k", "foo", "bar"} {
+		d.Add(NewJob(nil, org))
+	}
+	d.Start(ctx)
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		mu.Lock()
+		n := len(idles)
+		mu.Unlock()
+		if n > 0 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	if len(idles) != 1 || idles[0] != 3 {
+		t.Errorf("hook should be called once after all jobs: got %v", idles)
+	}
+}
+
+func TestDispatcherOnIdleReleasesWorker(t *testing.T) {
+	defaultMaxWorker := config.ServerConfig.MaxWorker
+	defer func() { config.ServerConfig.MaxWorker = defaultMaxWorker }()
+	config.ServerConfig.MaxWorker = 1
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	w := &countingWorker{}
+	d := NewDispatcher(w)
+	var once sync.Once
+	pushing, release := make(chan struct{}), make(chan struct{})
+	d.OnIdle(func(context.Context) {
+		once.Do(func() {
+			close(pushing)
+			<-release
+		})
+	})
+	d.Add(NewJob(nil, "ko-da-k"))
+	d.Start(ctx)
+	<-pushing
+	defer close(release)
+
+	// a slow push does not block the next job
+	d.Add(NewJob(nil, "foo"))
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		w.mu.Lock()
+		n := w.jobs
+		w.mu.Unlock()
+		if n == 2 {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Errorf("next job should run while the hook is running")
+}
@@ -0,0 +1,122 @@
+package exporter
+
+import (
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	httpRetriesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "github_exporter_http_retries_total",
+			Help: "How many GitHub API requests were retried.",
+		},
+		[]string{"reason"},
+	)
+	httpRetryGiveUpsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "github_exporter_http_retry_give_ups_total",
+			Help: "How many GitHub API requests failed after max attempts.",
+		},
+		[]string{"reason"},
+	)
+)
+
+// retryPolicy is exponential backoff with full jitter
+type retryPolicy struct {
+	maxAttempts int
+	baseDelay   time.Duration
+	maxDelay    time.Duration
+}
+
+// backoff returns the delay before the next attempt. attempt starts from 1.
+func (p retryPolicy) backoff(attempt int) time.Duration {
+	d := p.baseDelay << uint(attempt-1)
+	if d <= 0 || d > p.maxDelay {
+		d = p.maxDelay
+	}
+	return time.Duration(rand.Int63n(int64(d) + 1))
+}
+
+// retryTransport retries idempotent requests on 502, 503, 504 and network errors
+type retryTransport struct {
+	base   http.RoundTripper
+	policy retryPolicy
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return t.base.RoundTrip(req)
+	}
+
+	for attempt := 1; ; attempt++ {
+		resp, err := t.base.RoundTrip(req)
+		reason := retryReason(resp, err)
+		if reason == "" || req.Context().Err() != nil {
+			return resp, err
+		}
+		delay := t.policy.backoff(attempt)
+		if resp != nil {
+			if d, ok := retryAfter(resp.Header.Get("Retry-After")); ok {
+				delay = d
+			}
+		}
+		// the job should not be blocked longer than maxDelay, so leave it to the next job
+		if attempt >= t.policy.maxAttempts || delay > t.policy.maxDelay {
+			httpRetryGiveUpsTotal.WithLabelValues(reason).Inc()
+			return resp, err
+		}
+
+		if resp != nil {
+			// drain to reuse the connection
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		httpRetriesTotal.WithLabelValues(reason).Inc()
+		log.Warnf("retry %s %s in %s (attempt %d, %s)", req.Method, req.URL, delay, attempt, reason)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		}
+	}
+}
+
+// retryReason returns the reason to retry or empty if it should not be retried
+func retryReason(resp *http.Response, err error) string {
+	if err != nil {
+		return "network"
+	}
+	switch resp.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return strconv.Itoa(resp.StatusCode)
+	}
+	return ""
+}
+
+// retryAfter parses Retry-After header which is seconds or HTTP date
+func retryAfter(v string) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	if s, err := strconv.Atoi(v); err == nil {
+		return time.Duration(s) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
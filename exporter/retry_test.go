@@ -0,0 +1,131 @@
+package exporter
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/ko-da-k/github-developer-exporter/config"
+)
+
+// newFakeGitHub starts GitHub API fake which fails the first failures requests with status
+// and points config.GitHubConfig.URL to it until teardown.
+func newFakeGitHub(failures int, status int, header http.Header) (*int, func()) {
+	requests := 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("/orgs/ko-da-k", func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests <= failures {
+			for k, v := range header {
+				w.Header()[k] = v
+			}
+			w.WriteHeader(status)
+			return
+		}
+		fmt.Fprint(w, `{"login":"ko-da-k"}`)
+	})
+	server := httptest.NewServer(mux)
+
+	url := config.GitHubConfig.URL
+	delay := config.GitHubConfig.RetryBaseDelay
+	config.GitHubConfig.URL = server.URL + "/"
+	config.GitHubConfig.RetryBaseDelay = time.Millisecond
+	return &requests, func() {
+		server.Close()
+		config.GitHubConfig.URL = url
+		config.GitHubConfig.RetryBaseDelay = delay
+	}
+}
+
+func TestRetryTransport(t *testing.T) {
+	requests, teardown := newFakeGitHub(2, http.StatusServiceUnavailable, nil)
+	defer teardown()
+	client, err := NewGitHubClient(context.Background())
+	if err != nil {
+		t.Fatalf("%+v\n", err)
+	}
+	retries := testutil.ToFloat64(httpRetriesTotal.WithLabelValues("503"))
+
+	org, _, err := client.Organizations.Get(context.Background(), "ko-da-k")
+	if err != nil {
+		t.Fatalf("%+v\n", err)
+	}
+	if org.GetLogin() != "ko-da-k" {
+		t.Errorf("unexpected org: got %v want %v", org.GetLogin(), "ko-da-k")
+	}
+	if *requests != 3 {
+		t.Errorf("unexpected requests: got %v want %v", *requests, 3)
+	}
+	if got := testutil.ToFloat64(httpRetriesTotal.WithLabelValues("503")) - retries; got != 2 {
+		t.Errorf("unexpected retries: got %v want %v", got, 2)
+	}
+}
+
+func TestRetryTransportGiveUp(t *testing.T) {
+	requests, teardown := newFakeGitHub(10, http.StatusBadGateway, nil)
+	defer teardown()
+	client, err := NewGitHubClient(context.Background())
+	if err != nil {
+		t.Fatalf("%+v\n", err)
+	}
+	giveUps := testutil.ToFloat64(httpRetryGiveUpsTotal.WithLabelValues("502"))
+
+	if _, _, err := client.Organizations.Get(context.Background(), "ko-da-k"); err == nil {
+		t.Errorf("error should be returned after max attempts")
+	}
+	if *requests != config.GitHubConfig.RetryMaxAttempts {
+		t.Errorf("unexpected requests: got %v want %v", *requests, config.GitHubConfig.RetryMaxAttempts)
+	}
+	if got := testutil.ToFloat64(httpRetryGiveUpsTotal.WithLabelValues("502")) - giveUps; got != 1 {
+		t.Errorf("unexpected give ups: got %v want %v", got, 1)
+	}
+}
+
+func TestRetryTransportRetryAfter(t *testing.T) {
+	requests, teardown := newFakeGitHub(1, http.StatusServiceUnavailable, http.Header{"Retry-After": {"1"}})
+	defer teardown()
+	client, err := NewGitHubClient(context.Background())
+	if err != nil {
+		t.Fatalf("%+v\n", err)
+	}
+
+	start := time.Now()
+	if _, _, err := client.Organizations.Get(context.Background(), "ko-da-k"); err != nil {
+		t.Fatalf("%+v\n", err)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("Retry-After should be honoured: got %v want >= 1s", elapsed)
+	}
+	if *requests != 2 {
+		t.Errorf("unexpected requests: got %v want %v", *requests, 2)
+	}
+}
+
+func TestRetryTransportRetryAfterTooLong(t *testing.T) {
+	requests, teardown := newFakeGitHub(1, http.StatusServiceUnavailable, http.Header{"Retry-After": {"3600"}})
+	defer teardown()
+	client, err := NewGitHubClient(context.Background())
+	if err != nil {
+		t.Fatalf("%+v\n", err)
+	}
+	before := testutil.ToFloat64(httpRetryGiveUpsTotal.WithLabelValues("503"))
+
+	start := time.Now()
+	if _, _, err := client.Organizations.Get(context.Background(), "ko-da-k"); err == nil {
+		t.Fatalf("Retry-After longer than max delay should not be waited for")
+	}
+	if elapsed := time.Since(start); elapsed > config.GitHubConfig.RetryMaxDelay {
+		t.Errorf("unexpected wait: got %v want <= %v", elapsed, config.GitHubConfig.RetryMaxDelay)
+	}
+	if *requests != 1 {
+		t.Errorf("unexpected requests: got %v want %v", *requests, 1)
+	}
+	if got := testutil.ToFloat64(httpRetryGiveUpsTotal.WithLabelValues("503")) - before; got != 1 {
+		t.Errorf("unexpected give ups: got %v want %v", got, 1)
+	}
+}
@@ -0,0 +1,102 @@
+package exporter
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v28/github"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/ko-da-k/github-developer-exporter/config"
+)
+
+func TestSetReviewCacheByRepo(t *testing.T) {
+	now := time.Now()
+	recent := now.Add(-24 * time.Hour).Format(time.RFC3339)
+	old := now.AddDate(0, 0, -config.ReviewConfig.Window-1).Format(time.RFC3339)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/ko-da-k/hoge/pulls/1/reviews", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("page") == "2" {
+			fmt.Fprintf(w, `[{"user":{"login":"alice"},"state":"APPROVED","submitted_at":%q}]`, old)
+			return
+		}
+		w.Header().Set("Link", fmt.Sprintf(`<http://%s%s?page=2>; rel="next"`, r.Host, r.URL.Path))
+		fmt.Fprintf(w, `[{"user":{"login":"alice"},"state":"APPROVED","submitted_at":%q},`+
+			`{"user":{"login":"alice"},"state":"COMMENTED","submitted_at":%q},`+
+			`{"user":{"login":"bob"},"state":"APPROVED","submitted_at":%q}]`, recent, recent, recent)
+	})
+	mux.HandleFunc("/repos/ko-da-k/hoge/pulls/2/reviews", func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("reviews of pull requests updated before the window should not be fetched")
+		fmt.Fprint(w, `[]`)
+	})
+	mux.HandleFunc("/repos/ko-da-k/hoge/pulls/comments", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("since") == "" {
+			t.Errorf("review comments should be fetched since the window")
+		}
+		fmt.Fprintf(w, `[{"user":{"login":"alice"},"created_at":%q},`+
+			`{"user":{"login":"alice"},"created_at":%q},`+
+			`{"user":{"login":"bob"},"created_at":%q}]`, recent, recent, old)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+	defer Kv.Flush()
+
+	updated := now.Add(-time.Hour)
+	stale := now.AddDate(0, 0, -config.ReviewConfig.Window-1)
+	pulls := []*github.PullRequest{
+		{Number: github.Int(1), UpdatedAt: &updated},
+		{Number: github.Int(2), UpdatedAt: &stale},
+	}
+	j := NewJob(client, "ko-da-k")
+	if err := j.setReviewCacheByRepo(context.Background(), "hoge", pulls); err != nil {
+		t.Fatalf("%+v\n", err)
+	}
+
+	ch := make(chan prometheus.Metric, 16)
+	c := &devCollector{}
+	c.setReviewMetrics(ch, NewGitHubCollector("ko-da-k"), repoRef{name: "hoge", label: "hoge"})
+	close(ch)
+
+	reviews := make(map[string]float64)
+	comments := make(map[string]float64)
+	for m := range ch {
+		pb := &dto.Metric{}
+		if err := m.Write(pb); err != nil {
+			t.Fatalf("%+v\n", err)
+		}
+		labels := make(map[string]string)
+		for _, l := range pb.GetLabel() {
+			labels[l.GetName()] = l.GetValue()
+		}
+		switch m.Desc() {
+		case reviewerReviewsCount:
+			reviews[labels["reviewer"]+"/"+labels["state"]] = pb.GetGauge().GetValue()
+		case reviewerReviewCommentsCount:
+			comments[labels["reviewer"]] = pb.GetGauge().GetValue()
+		}
+	}
+
+	// the old review on the second page is out of the window
+	expectedReviews := map[string]float64{"alice/APPROVED": 1, "alice/COMMENTED": 1, "bob/APPROVED": 1}
+	if len(reviews) != len(expectedReviews) {
+		t.Errorf("unexpected reviews: got %v want %v", reviews, expectedReviews)
+	}
+	for k, v := range expectedReviews {
+		if reviews[k] != v {
+			t.Errorf("%s: unexpected reviews: got %v want %v", k, reviews[k], v)
+		}
+	}
+	// the old comment of bob is out of the window
+	if len(comments) != 1 || comments["alice"] != 2 {
+		t.Errorf("unexpected review comments: got %v want map[alice:2]", comments)
+	}
+}
@@ -0,0 +1,153 @@
+package exporter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	ErrUnknownOrg = errors.New("org is not configured")
+	ErrQueueFull  = errors.New("job queue is full")
+)
+
+// Scheduler adds jobs of all orgs to the dispatcher at the interval
+// and lets operators refresh, pause, resume and purge them.
+type Scheduler struct {
+	dispatcher *Dispatcher
+	jobs       []*Job
+	interval   time.Duration
+
+	mu     sync.RWMutex
+	paused bool
+	// startedAt is the baseline of job progress before any job finishes
+	startedAt time.Time
+}
+
+func NewScheduler(dispatcher *Dispatcher, jobs []*Job, interval time.Duration) *Scheduler {
+	return &Scheduler{
+		dispatcher: dispatcher,
+		jobs:       jobs,
+		interval:   interval,
+	}
+}
+
+// Start adds all jobs at once and then at the interval until ctx is done
+func (s *Scheduler) Start(ctx context.Context) {
+	s.mu.Lock()
+	s.startedAt = time.Now()
+	s.mu.Unlock()
+	go func() {
+		// initialized
+		for _, job := range s.jobs {
+			s.dispatcher.Add(job)
+		}
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				log.Warnf("stop loop api call")
+				return
+			case <-ticker.C:
+				if s.Paused() {
+					log.Infof("skip scheduled jobs because scheduler is paused")
+					continue
+				}
+				for _, job := range s.jobs {
+					s.dispatcher.Add(job)
+				}
+			}
+		}
+	}()
+}
+
+// Pause skips scheduled jobs. Running and queued jobs still finish.
+func (s *Scheduler) Pause() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.paused = true
+}
+
+// Resume schedules jobs again from the next tick
+func (s *Scheduler) Resume() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.paused {
+		// jobs did not progress while paused
+		s.startedAt = time.Now()
+	}
+	s.paused = false
+}
+
+func (s *Scheduler) Paused() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.paused
+}
+
+// Refresh adds jobs of the org, or all orgs if org is empty, without waiting for the interval.
+// It works even if the scheduler is paused, and returns ErrQueueFull instead of blocking.
+func (s *Scheduler) Refresh(org string) ([]string, error) {
+	jobs, err := s.selectJobs(org)
+	if err != nil {
+		return nil, err
+	}
+	var queued []string
+	for _, job := range jobs {
+		if !s.dispatcher.TryAdd(job) {
+			return queued, ErrQueueFull
+		}
+		queued = append(queued, job.orgName)
+	}
+	return queued, nil
+}
+
+// Purge deletes cached data of the org, or all orgs if org is empty.
+// The next job fetches all repositories even if events polling is enabled.
+func (s *Scheduler) Purge(org string) ([]string, error) {
+	jobs, err := s.selectJobs(org)
+	if err != nil {
+		return nil, err
+	}
+	var purged []string
+	for _, job := range jobs {
+		purgeOrgCache(job.orgName)
+		job.fullSyncRequested.Store(true)
+		snapshots.Invalidate(job.orgName)
+		purged = append(purged, job.orgName)
+	}
+	return purged, nil
+}
+
+func (s *Scheduler) selectJobs(org string) ([]*Job, error) {
+	if org == "" {
+		return s.jobs, nil
+	}
+	for _, job := range s.jobs {
+		if job.orgName == org {
+			return []*Job{job}, nil
+		}
+	}
+	return nil, fmt.Errorf("%s: %w", org, ErrUnknownOrg)
+}
+
+// purgeOrgCache deletes cached data of the org.
+// Keys are built from cached repositories not to delete another org which has the org name as prefix.
+func purgeOrgCache(org string) {
+	g := NewGitHubCollector(org)
+	if repos, err := g.GetReposByOrg(); err == nil {
+		for _, repo := range repos {
+			for _, suffix := range append(repoCacheSuffixes, "workflow-runs") {
+				Kv.Delete(fmt.Sprintf("%s-%s-%s", org, repo.GetName(), suffix))
+			}
+		}
+	}
+	Kv.Delete(fmt.Sprintf("%s-repos", org))
+	Kv.Delete(fmt.Sprintf("%s-team-members", org))
+	Kv.Delete(org)
+}
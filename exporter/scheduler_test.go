@@ -0,0 +1,66 @@
+package exporter
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ko-da-k/github-developer-exporter/config"
+)
+
+func TestSchedulerRefresh(t *testing.T) {
+	// the dispatcher is not started, so jobs stay in the queue
+	d := NewDispatcher(NewWorker())
+	s := NewScheduler(d, []*Job{NewJob(nil, "ko-da-k"), NewJob(nil, "foo")}, time.Minute)
+
+	if _, err := s.Refresh("unknown"); !errors.Is(err, ErrUnknownOrg) {
+		t.Errorf("unexpected error: got %v want %v", err, ErrUnknownOrg)
+	}
+	queued, err := s.Refresh("ko-da-k")
+	if err != nil || len(queued) != 1 || queued[0] != "ko-da-k" {
+		t.Errorf("unexpected queued: %v, %v", queued, err)
+	}
+	for i := 1; i < config.ServerConfig.MaxQueue; i++ {
+		s.Refresh("foo")
+	}
+	if _, err := s.Refresh(""); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("unexpected error: got %v want %v", err, ErrQueueFull)
+	}
+	if status := d.Status(); status.QueuedJobs != config.ServerConfig.MaxQueue {
+		t.Errorf("unexpected queued jobs: got %v want %v", status.QueuedJobs, config.ServerConfig.MaxQueue)
+	}
+
+	s.Pause()
+	if !s.Paused() {
+		t.Errorf("scheduler should be paused")
+	}
+	s.Resume()
+	if s.Paused() {
+		t.Errorf("scheduler should be resumed")
+	}
+}
+
+func TestSchedulerPurge(t *testing.T) {
+	setTestCache("foo")
+	setTestCache("foo-hoge")
+	defer Kv.Flush()
+	job := NewJob(nil, "foo")
+	s := NewScheduler(NewDispatcher(NewWorker()), []*Job{job, NewJob(nil, "foo-hoge")}, time.Minute)
+
+	purged, err := s.Purge("foo")
+	if err != nil || len(purged) != 1 {
+		t.Fatalf("unexpected purged: %v, %v", purged, err)
+	}
+	for _, key := range []string{"foo", "foo-repos", "foo-hoge-issues", "foo-hoge-pulls"} {
+		if _, found := Kv.Get(key); found {
+			t.Errorf("%s should be purged", key)
+		}
+	}
+	// the org which has the purged org name as prefix is kept
+	if _, found := Kv.Get("foo-hoge-repos"); !found {
+		t.Errorf("foo-hoge-repos should not be purged")
+	}
+	if !job.fullSyncRequested.Load() {
+		t.Errorf("full sync should be requested")
+	}
+}
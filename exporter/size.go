@@ -0,0 +1,111 @@
+package exporter
+
+import (
+	"math"
+	"strconv"
+
+	"github.com/google/go-github/v28/github"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	// sizeNames are labels of pull request size from small to large
+	sizeNames = []string{"XS", "S", "M", "L", "XL", "XXL"}
+
+	changedFilesBuckets = []float64{1, 2, 5, 10, 20, 50, 100}
+	commitsBuckets      = []float64{1, 2, 5, 10, 20, 50}
+)
+
+// sizeLabel returns the size label of changed lines.
+// buckets are upper bounds of each label sorted in ascending order.
+func sizeLabel(lines int, buckets []int) string {
+	for i, b := range buckets {
+		if lines <= b {
+			return sizeNames[i]
+		}
+	}
+	return sizeNames[len(buckets)]
+}
+
+// pullRequestStatus returns "merged" instead of "closed" if the pull request was merged
+func pullRequestStatus(pull *github.PullRequest) string {
+	if !pull.GetMergedAt().IsZero() {
+		return "merged"
+	}
+	return pull.GetState()
+}
+
+// histogram aggregates values into prometheus histogram values.
+type histogram struct {
+	count   uint64
+	sum     float64
+	buckets map[float64]uint64
+	// exemplars are the largest observation of each bucket keyed by its upper bound,
+	// so a slow bucket links to the worst pull request in it.
+	exemplars map[float64]prometheus.Exemplar
+}
+
+func newHistogram(bounds []float64) *histogram {
+	buckets := make(map[float64]uint64, len(bounds))
+	for _, b := range bounds {
+		buckets[b] = 0
+	}
+	return &histogram{buckets: buckets, exemplars: make(map[float64]prometheus.Exemplar)}
+}
+
+func (h *histogram) observe(v float64) {
+	h.count++
+	h.sum += v
+	for b := range h.buckets {
+		if v <= b {
+			h.buckets[b]++
+		}
+	}
+}
+
+// observePullRequest observes v with pr_url and number of the pull request as an exemplar.
+// It has no exemplar if url is empty, e.g. of a redacted repository.
+func (h *histogram) observePullRequest(v float64, url string, number int) {
+	h.observe(v)
+	if url == "" {
+		return
+	}
+
+	bound := math.Inf(1)
+	for b := range h.buckets {
+		if v <= b && b < bound {
+			bound = b
+		}
+	}
+	if e, ok := h.exemplars[bound]; ok && e.Value >= v {
+		return
+	}
+	h.exemplars[bound] = prometheus.Exemplar{
+		Value: v,
+		Labels: prometheus.Labels{
+			"pr_url": url,
+			"number": strconv.Itoa(number),
+		},
+	}
+}
+
+// metric returns a const histogram with the exemplars.
+// Exemplars are exposed only in OpenMetrics format.
+func (h *histogram) metric(desc *prometheus.Desc, labelValues ...string) prometheus.Metric {
+	m := prometheus.MustNewConstHistogram(desc, h.count, h.sum, h.buckets, labelValues...)
+	if len(h.exemplars) == 0 {
+		return m
+	}
+	exemplars := make([]prometheus.Exemplar, 0, len(h.exemplars))
+	for _, e := range h.exemplars {
+		exemplars = append(exemplars, e)
+	}
+	withExemplars, err := prometheus.NewMetricWithExemplars(m, exemplars...)
+	if err != nil {
+		// e.g. labels longer than 128 characters
+		log.Debugf("drop exemplars of %v: %v", desc, err)
+		return m
+	}
+	return withExemplars
+}
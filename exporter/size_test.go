@@ -0,0 +1,73 @@
+package exporter
+
+import (
+	"math"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestSizeLabel(t *testing.T) {
+	buckets := []int{10, 100, 500, 1000}
+	cases := map[int]string{
+		0:    "XS",
+		10:   "XS",
+		11:   "S",
+		500:  "M",
+		999:  "L",
+		1001: "XL",
+	}
+	for lines, expected := range cases {
+		if actual := sizeLabel(lines, buckets); actual != expected {
+			t.Errorf("sizeLabel(%d): got %v want %v", lines, actual, expected)
+		}
+	}
+
+	// the max 5 buckets give XXL over the last bound
+	buckets = []int{10, 100, 500, 1000, 5000}
+	cases = map[int]string{
+		5000: "XL",
+		5001: "XXL",
+	}
+	for lines, expected := range cases {
+		if actual := sizeLabel(lines, buckets); actual != expected {
+			t.Errorf("sizeLabel(%d): got %v want %v", lines, actual, expected)
+		}
+	}
+}
+
+func TestHistogramExemplars(t *testing.T) {
+	h := newHistogram([]float64{10, 100})
+	h.observePullRequest(5, "https://github.com/ko-da-k/hoge/pull/1", 1)
+	h.observePullRequest(8, "https://github.com/ko-da-k/hoge/pull/2", 2)
+	h.observePullRequest(50, "https://github.com/ko-da-k/hoge/pull/3", 3)
+	h.observePullRequest(500, "https://github.com/ko-da-k/hoge/pull/4", 4)
+
+	desc := prometheus.NewDesc("test_histogram", "test", nil, nil)
+	pb := &dto.Metric{}
+	if err := h.metric(desc).Write(pb); err != nil {
+		t.Fatalf("%+v\n", err)
+	}
+	// the largest observation of each bucket
+	expected := map[float64]string{
+		10:          "2",
+		100:         "3",
+		math.Inf(1): "4",
+	}
+	buckets := pb.GetHistogram().GetBucket()
+	if len(buckets) != len(expected) {
+		t.Fatalf("unexpected buckets: got %v", buckets)
+	}
+	for _, b := range buckets {
+		var number string
+		for _, l := range b.GetExemplar().GetLabel() {
+			if l.GetName() == "number" {
+				number = l.GetValue()
+			}
+		}
+		if want := expected[b.GetUpperBound()]; number != want {
+			t.Errorf("unexpected exemplar of bucket %v: got %v want %v", b.GetUpperBound(), number, want)
+		}
+	}
+}
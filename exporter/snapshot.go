@@ -0,0 +1,136 @@
+package exporter
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/ko-da-k/github-developer-exporter/config"
+)
+
+// snapshots are pre-rendered metrics of each org
+var snapshots = newSnapshotStore()
+
+// snapshot is an immutable set of metrics of an org.
+// Data only change when a job finishes or a webhook event arrives,
+// so scrapes stream it instead of rebuilding metrics from cache.
+type snapshot struct {
+	org     string
+	ok      bool
+	metrics []prometheus.Metric
+	// builtAt is when the build started, so the snapshot has no data newer than it
+	builtAt time.Time
+}
+
+// snapshotBuild is a build in progress which concurrent scrapes wait for
+type snapshotBuild struct {
+	done chan struct{}
+	snap *snapshot
+}
+
+type snapshotStore struct {
+	mu        sync.RWMutex
+	snapshots map[string]*snapshot
+	// dirty orgs are rebuilt at the next scrape. The value is when it was invalidated.
+	dirty map[string]time.Time
+	// builds are rebuilds by scrapes in progress
+	builds map[string]*snapshotBuild
+}
+
+func newSnapshotStore() *snapshotStore {
+	return &snapshotStore{
+		snapshots: make(map[string]*snapshot),
+		dirty:     make(map[string]time.Time),
+		builds:    make(map[string]*snapshotBuild),
+	}
+}
+
+// Get returns the snapshot of the org and rebuilds it if needed.
+// Concurrent scrapes share one rebuild.
+func (s *snapshotStore) Get(org string) *snapshot {
+	s.mu.RLock()
+	snap, dirty := s.snapshots[org], !s.dirty[org].IsZero()
+	s.mu.RUnlock()
+
+	// the snapshot expires with cached data
+	ttl := time.Duration(config.GitHubConfig.Interval+3) * time.Minute
+	if snap != nil && !dirty && time.Since(snap.builtAt) < ttl {
+		return snap
+	}
+
+	s.mu.Lock()
+	build, ok := s.builds[org]
+	if !ok {
+		build = &snapshotBuild{done: make(chan struct{})}
+		s.builds[org] = build
+	}
+	s.mu.Unlock()
+	if ok {
+		<-build.done
+		return build.snap
+	}
+
+	build.snap = s.Refresh(org)
+	s.mu.Lock()
+	delete(s.builds, org)
+	s.mu.Unlock()
+	close(build.done)
+	return build.snap
+}
+
+// Refresh rebuilds the snapshot of the org from cache
+func (s *snapshotStore) Refresh(org string) *snapshot {
+	snap := buildSnapshot(org)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// a slower build started earlier must not overwrite newer data
+	if cur := s.snapshots[org]; cur != nil && cur.builtAt.After(snap.builtAt) {
+		return cur
+	}
+	s.snapshots[org] = snap
+	// keep it dirty if invalidated during the build
+	if invalidated, ok := s.dirty[org]; ok && invalidated.Before(snap.builtAt) {
+		delete(s.dirty, org)
+	}
+	return snap
+}
+
+// Invalidate marks the snapshot of the org to be rebuilt at the next scrape.
+// It is used by webhook events not to rebuild for each event.
+func (s *snapshotStore) Invalidate(org string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dirty[org] = time.Now()
+}
+
+// buildSnapshot renders metrics of the org from cache
+func buildSnapshot(org string) *snapshot {
+	builtAt := time.Now()
+	c := &devCollector{}
+	ch := make(chan prometheus.Metric, 1024)
+	done := make(chan bool)
+	go func() {
+		defer close(ch)
+		done <- c.collectOrgMetrics(ch, NewGitHubCollector(org))
+	}()
+
+	var metrics []prometheus.Metric
+	for {
+		select {
+		case m := <-ch:
+			metrics = append(metrics, m)
+		case ok := <-done:
+			for m := range ch {
+				metrics = append(metrics, m)
+			}
+			return &snapshot{
+				org:     org,
+				ok:      ok,
+				metrics: metrics,
+				builtAt: builtAt,
+			}
+		}
+	}
+}
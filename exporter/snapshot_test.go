@@ -0,0 +1,135 @@
+package exporter
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v28/github"
+	"github.com/patrickmn/go-cache"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+func TestSnapshot(t *testing.T) {
+	setTestCache("snapshot")
+	defer Kv.Flush()
+
+	snap := snapshots.Refresh("snapshot")
+	if !snap.ok {
+		t.Fatalf("snapshot should be ok")
+	}
+	descs := make(map[*prometheus.Desc]bool)
+	for _, m := range snap.metrics {
+		descs[m.Desc()] = true
+	}
+	for _, desc := range []*prometheus.Desc{orgInfo, repoInfo, issueInfo, pullRequestInfo} {
+		if !descs[desc] {
+			t.Errorf("%s should be in the snapshot", desc)
+		}
+	}
+
+	// cache changes are not visible until the snapshot is rebuilt
+	Kv.Delete("snapshot")
+	if got := snapshots.Get("snapshot"); got != snap {
+		t.Errorf("snapshot should be reused")
+	}
+	snapshots.Invalidate("snapshot")
+	if got := snapshots.Get("snapshot"); got == snap || got.ok {
+		t.Errorf("snapshot should be rebuilt: got %v", got.ok)
+	}
+}
+
+func TestSnapshotConcurrentRebuild(t *testing.T) {
+	setTestCache("snapshot")
+	defer Kv.Flush()
+	snapshots.Refresh("snapshot")
+	snapshots.Invalidate("snapshot")
+
+	// concurrent scrapes wait for one rebuild instead of rebuilding each
+	var wg sync.WaitGroup
+	got := make([]*snapshot, 10)
+	for i := range got {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			got[i] = snapshots.Get("snapshot")
+		}(i)
+	}
+	wg.Wait()
+	for i := range got {
+		if got[i] != got[0] {
+			t.Fatalf("scrapes should share the rebuilt snapshot")
+		}
+	}
+}
+
+// setBenchmarkCache caches an org which has 1000 repositories with 100 pull requests each
+func setBenchmarkCache(orgName string) {
+	now := time.Now()
+	user := &github.User{Login: github.String("alice")}
+	Kv.Set(orgName, &github.Organization{Login: github.String(orgName)}, cache.DefaultExpiration)
+	repos := make([]*github.Repository, 1000)
+	for i := range repos {
+		name := fmt.Sprintf("repo%d", i)
+		repos[i] = &github.Repository{Name: github.String(name)}
+		pulls := make([]*github.PullRequest, 100)
+		details := make(map[int]*github.PullRequest, len(pulls))
+		for j := range pulls {
+			pulls[j] = &github.PullRequest{
+				Number:    github.Int(j),
+				State:     github.String("open"),
+				UpdatedAt: &now,
+				User:      user,
+			}
+			details[j] = pulls[j]
+		}
+		Kv.Set(fmt.Sprintf("%s-%s-pulls", orgName, name), pulls, cache.DefaultExpiration)
+		Kv.Set(fmt.Sprintf("%s-%s-issues", orgName, name), []*github.Issue{}, cache.DefaultExpiration)
+		Kv.Set(fmt.Sprintf("%s-%s-pull-details", orgName, name), details, cache.DefaultExpiration)
+		Kv.Set(fmt.Sprintf("%s-%s-reviews", orgName, name), map[int][]*github.PullRequestReview{}, cache.DefaultExpiration)
+		Kv.Set(fmt.Sprintf("%s-%s-review-comments", orgName, name), []*github.PullRequestComment{}, cache.DefaultExpiration)
+	}
+	Kv.Set(orgName+"-repos", repos, cache.DefaultExpiration)
+}
+
+func drain(c prometheus.Collector) {
+	ch := make(chan prometheus.Metric, 1024)
+	go func() {
+		c.Collect(ch)
+		close(ch)
+	}()
+	for range ch {
+	}
+}
+
+// BenchmarkCollect streams the pre-rendered snapshot as a scrape does
+func BenchmarkCollect(b *testing.B) {
+	log.SetLevel(log.FatalLevel)
+	defer log.SetLevel(log.InfoLevel)
+	setBenchmarkCache("bench")
+	defer Kv.Flush()
+	snapshots.Refresh("bench")
+	c := NewDevCollector([]*GitHubCollector{NewGitHubCollector("bench")})
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		drain(c)
+	}
+}
+
+// BenchmarkBuildSnapshot renders metrics from cache as a job does when it finishes
+func BenchmarkBuildSnapshot(b *testing.B) {
+	log.SetLevel(log.FatalLevel)
+	defer log.SetLevel(log.InfoLevel)
+	setBenchmarkCache("bench")
+	defer Kv.Flush()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		buildSnapshot("bench")
+	}
+}
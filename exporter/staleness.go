@@ -0,0 +1,220 @@
+package exporter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v28/github"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/ko-da-k/github-developer-exporter/config"
+)
+
+const (
+	classFresh     = "fresh"
+	classStale     = "stale"
+	classAbandoned = "abandoned"
+)
+
+var (
+	stalenessClasses = []string{classFresh, classStale, classAbandoned}
+
+	// staleness is built from StaleConfig
+	staleness *StalenessEvaluator
+)
+
+func init() {
+	var err error
+	staleness, err = NewStalenessEvaluator(
+		days(config.StaleConfig.After),
+		days(config.StaleConfig.AbandonedAfter),
+		config.StaleConfig.Rules,
+	)
+	if err != nil {
+		log.Fatalf("stale config error: %+v", err)
+	}
+}
+
+// threshold is periods after which an untouched item becomes stale or abandoned
+type threshold struct {
+	stale     time.Duration
+	abandoned time.Duration
+}
+
+// StalenessEvaluator classifies open pull requests and issues into fresh, stale or abandoned
+// by how long they are untouched.
+// Thresholds are looked up in order of label, repository, organization and global.
+type StalenessEvaluator struct {
+	global threshold
+	// key is org name
+	orgs map[string]threshold
+	// key is "<org>/<repo>"
+	repos map[string]threshold
+	// key is label name
+	labels map[string]threshold
+}
+
+// NewStalenessEvaluator constructor
+// rules are comma separated "<selector>=<stale days>:<abandoned days>".
+// selector is "<org>", "<org>/<repo>" or "label:<label name>".
+func NewStalenessEvaluator(stale, abandoned time.Duration, rules string) (*StalenessEvaluator, error) {
+	if stale > abandoned {
+		return nil, fmt.Errorf("stale period %s must not be larger than abandoned period %s", stale, abandoned)
+	}
+	e := &StalenessEvaluator{
+		global: threshold{stale, abandoned},
+		orgs:   make(map[string]threshold),
+		repos:  make(map[string]threshold),
+		labels: make(map[string]threshold),
+	}
+	for _, rule := range strings.Split(rules, ",") {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+		i := strings.LastIndex(rule, "=")
+		if i < 0 {
+			return nil, fmt.Errorf("invalid rule %q: '=' not found", rule)
+		}
+		selector, periods := rule[:i], rule[i+1:]
+		t, err := parseThreshold(periods)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rule %q: %w", rule, err)
+		}
+		switch {
+		case strings.HasPrefix(selector, "label:"):
+			e.labels[strings.TrimPrefix(selector, "label:")] = t
+		case strings.Contains(selector, "/"):
+			e.repos[selector] = t
+		default:
+			e.orgs[selector] = t
+		}
+	}
+	return e, nil
+}
+
+func parseThreshold(s string) (threshold, error) {
+	ps := strings.Split(s, ":")
+	if len(ps) != 2 {
+		return threshold{}, fmt.Errorf("periods must be <stale days>:<abandoned days>")
+	}
+	stale, err := strconv.ParseFloat(ps[0], 32)
+	if err != nil {
+		return threshold{}, fmt.Errorf("invalid stale days: %w", err)
+	}
+	abandoned, err := strconv.ParseFloat(ps[1], 32)
+	if err != nil {
+		return threshold{}, fmt.Errorf("invalid abandoned days: %w", err)
+	}
+	if stale > abandoned {
+		return threshold{}, fmt.Errorf("stale days must not be larger than abandoned days")
+	}
+	return threshold{days(float32(stale)), days(float32(abandoned))}, nil
+}
+
+// threshold returns the threshold for the item.
+// If multiple labels match, the shortest stale period is used.
+func (e *StalenessEvaluator) threshold(org, repo string, labels []string) threshold {
+	var (
+		t     threshold
+		found bool
+	)
+	for _, label := range labels {
+		if lt, ok := e.labels[label]; ok && (!found || lt.stale < t.stale) {
+			t = lt
+			found = true
+		}
+	}
+	if found {
+		return t
+	}
+	if rt, ok := e.repos[org+"/"+repo]; ok {
+		return rt
+	}
+	if ot, ok := e.orgs[org]; ok {
+		return ot
+	}
+	return e.global
+}
+
+// Classify returns fresh, stale or abandoned by the idle duration
+func (e *StalenessEvaluator) Classify(org, repo string, labels []string, idle time.Duration) string {
+	t := e.threshold(org, repo, labels)
+	switch {
+	case idle >= t.abandoned:
+		return classAbandoned
+	case idle >= t.stale:
+		return classStale
+	default:
+		return classFresh
+	}
+}
+
+// lastReviewActivity returns the latest review or review comment time of each pull request
+func lastReviewActivity(reviews map[int][]*github.PullRequestReview, comments []*github.PullRequestComment) map[int]time.Time {
+	last := make(map[int]time.Time)
+	for number, rs := range reviews {
+		for _, r := range rs {
+			if r.GetSubmittedAt().After(last[number]) {
+				last[number] = r.GetSubmittedAt()
+			}
+		}
+	}
+	for _, c := range comments {
+		// pull_request_url is ".../pulls/<number>"
+		u := c.GetPullRequestURL()
+		number, err := strconv.Atoi(u[strings.LastIndex(u, "/")+1:])
+		if err != nil {
+			continue
+		}
+		if c.GetCreatedAt().After(last[number]) {
+			last[number] = c.GetCreatedAt()
+		}
+	}
+	return last
+}
+
+// assigneeStaleness counts open items of each assignee by staleness class in the organization
+type assigneeStaleness struct {
+	// key is kind ("pull_request" or "issue"), assignee and class
+	counts map[[3]string]int
+}
+
+func newAssigneeStaleness() *assigneeStaleness {
+	return &assigneeStaleness{make(map[[3]string]int)}
+}
+
+// add counts the item once for each assignee label value,
+// which may be the same for different assignees if logins are dropped or truncated.
+func (a *assigneeStaleness) add(kind string, assignees []*github.User, class string) {
+	if len(assignees) == 0 {
+		a.counts[[3]string{kind, "", class}]++
+		return
+	}
+	seen := make(map[string]bool, len(assignees))
+	for _, assignee := range assignees {
+		login := privacy.Login(assignee.GetLogin())
+		if seen[login] {
+			continue
+		}
+		seen[login] = true
+		a.counts[[3]string{kind, login, class}]++
+	}
+}
+
+// latest returns the latest time
+func latest(ts ...time.Time) time.Time {
+	var l time.Time
+	for _, t := range ts {
+		if t.After(l) {
+			l = t
+		}
+	}
+	return l
+}
+
+func days(d float32) time.Duration {
+	return time.Duration(float64(d) * 24 * float64(time.Hour))
+}
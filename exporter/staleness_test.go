@@ -0,0 +1,48 @@
+package exporter
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStalenessEvaluator(t *testing.T) {
+	e, err := NewStalenessEvaluator(days(7), days(30), "my-org=3:14, my-org/my-repo=1:7,label:wip=14:60")
+	if err != nil {
+		t.Fatalf("%+v\n", err)
+	}
+
+	cases := []struct {
+		org      string
+		repo     string
+		labels   []string
+		idle     time.Duration
+		expected string
+	}{
+		{"other", "repo", nil, days(6), classFresh},
+		{"other", "repo", nil, days(7), classStale},
+		{"other", "repo", nil, days(30), classAbandoned},
+		{"my-org", "repo", nil, days(4), classStale},
+		{"my-org", "my-repo", nil, days(2), classStale},
+		{"my-org", "my-repo", nil, days(8), classAbandoned},
+		{"my-org", "my-repo", []string{"wip"}, days(8), classFresh},
+	}
+	for _, c := range cases {
+		if actual := e.Classify(c.org, c.repo, c.labels, c.idle); actual != c.expected {
+			t.Errorf("Classify(%s/%s %v %v): got %v want %v", c.org, c.repo, c.labels, c.idle, actual, c.expected)
+		}
+	}
+}
+
+func TestStalenessEvaluatorInvalidRules(t *testing.T) {
+	for _, rules := range []string{"my-org", "my-org=3", "my-org=a:b", "my-org=14:3"} {
+		if _, err := NewStalenessEvaluator(days(7), days(30), rules); err == nil {
+			t.Errorf("NewStalenessEvaluator(%q) should return error", rules)
+		}
+	}
+}
+
+func TestStalenessEvaluatorInvalidGlobal(t *testing.T) {
+	if _, err := NewStalenessEvaluator(days(30), days(7), ""); err == nil {
+		t.Errorf("NewStalenessEvaluator should return error if stale is larger than abandoned")
+	}
+}
@@ -0,0 +1,182 @@
+package exporter
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	outcomeSuccess = "success"
+	outcomeFailure = "failure"
+	// outcomePartial is a success where some repositories failed
+	outcomePartial = "partial"
+)
+
+// JobStatus is the state of the latest execution of a job
+type JobStatus struct {
+	Org            string    `json:"org"`
+	Running        bool      `json:"running"`
+	LastStartedAt  time.Time `json:"last_started_at"`
+	LastFinishedAt time.Time `json:"last_finished_at"`
+	// LastDurationSeconds is zero while the first execution is running
+	LastDurationSeconds float64 `json:"last_duration_seconds"`
+	// LastOutcome is "success", "partial" or "failure", or empty before the first execution finishes
+	LastOutcome   string    `json:"last_outcome"`
+	LastError     string    `json:"last_error"`
+	LastSuccessAt time.Time `json:"last_success_at"`
+	FailedRepos   int       `json:"failed_repos"`
+	// FailedRepoReasons are keyed by repository name label, which is a pseudonym if redacted
+	FailedRepoReasons map[string]string `json:"failed_repo_reasons,omitempty"`
+}
+
+// jobStatus is updated by the job and read by status page at any time,
+// so it has its own lock instead of the lock held during execution.
+type jobStatus struct {
+	mu     sync.RWMutex
+	status JobStatus
+}
+
+func (s *jobStatus) start(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status.Running = true
+	s.status.LastStartedAt = now
+}
+
+func (s *jobStatus) finish(now time.Time, failedRepos map[string]string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status.Running = false
+	s.status.LastFinishedAt = now
+	s.status.LastDurationSeconds = now.Sub(s.status.LastStartedAt).Seconds()
+	s.status.FailedRepos = len(failedRepos)
+	s.status.FailedRepoReasons = failedRepos
+	// the org and its repositories are fetched even if some repositories failed
+	var errs RepoErrors
+	switch {
+	case errors.As(err, &errs):
+		s.status.LastOutcome = outcomePartial
+		s.status.LastError = failedReposMessage(failedRepos)
+	case err != nil:
+		s.status.LastOutcome = outcomeFailure
+		s.status.LastError = err.Error()
+		return
+	default:
+		s.status.LastOutcome = outcomeSuccess
+		s.status.LastError = ""
+	}
+	s.status.LastSuccessAt = now
+}
+
+// failedReposMessage lists the failed repositories by label with their reasons.
+// It is not built from the errors, which have raw names of redacted repositories in messages and URLs.
+func failedReposMessage(reasons map[string]string) string {
+	failed := make([]string, 0, len(reasons))
+	for label, reason := range reasons {
+		failed = append(failed, fmt.Sprintf("%s (%s)", label, reason))
+	}
+	sort.Strings(failed)
+	return fmt.Sprintf("%d repositories failed: %s", len(reasons), strings.Join(failed, ", "))
+}
+
+func (s *jobStatus) get() JobStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.status
+}
+
+// Status returns the state of the latest execution
+func (j *Job) Status() JobStatus {
+	status := j.status.get()
+	status.Org = j.orgName
+	return status
+}
+
+// OrgStatus is the state of the job and the cache of an org
+type OrgStatus struct {
+	JobStatus
+	Repos        int `json:"repos"`
+	PullRequests int `json:"pull_requests"`
+	Issues       int `json:"issues"`
+	// DataAgeSeconds is since the last successful job. It is -1 if no job has succeeded.
+	DataAgeSeconds float64 `json:"data_age_seconds"`
+}
+
+// RateLimitStatus is the latest rate limit of GitHub API.
+// Remaining is -1 until the first response.
+type RateLimitStatus struct {
+	Limit     int       `json:"limit"`
+	Remaining int       `json:"remaining"`
+	Reset     time.Time `json:"reset"`
+}
+
+// DispatcherStatus is occupancy of the job queue and workers
+type DispatcherStatus struct {
+	QueuedJobs    int `json:"queued_jobs"`
+	QueueCapacity int `json:"queue_capacity"`
+	BusyWorkers   int `json:"busy_workers"`
+	Workers       int `json:"workers"`
+}
+
+type Status struct {
+	Orgs       []OrgStatus      `json:"orgs"`
+	RateLimit  RateLimitStatus  `json:"rate_limit"`
+	Dispatcher DispatcherStatus `json:"dispatcher"`
+	Time       time.Time        `json:"time"`
+}
+
+// Status returns the latest rate limit
+func (r *rateLimiter) Status() RateLimitStatus {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return RateLimitStatus{
+		Limit:     r.limit,
+		Remaining: r.remaining,
+		Reset:     r.reset,
+	}
+}
+
+// Status returns occupancy of the job queue and workers
+func (d *Dispatcher) Status() DispatcherStatus {
+	return DispatcherStatus{
+		QueuedJobs:    len(d.jobQueue),
+		QueueCapacity: cap(d.jobQueue),
+		BusyWorkers:   len(d.workerPool),
+		Workers:       cap(d.workerPool),
+	}
+}
+
+// CollectStatus returns the state of the jobs, the cache, the rate limit and the dispatcher
+func CollectStatus(jobs []*Job, d *Dispatcher) *Status {
+	now := time.Now()
+	s := &Status{
+		Orgs:       make([]OrgStatus, 0, len(jobs)),
+		RateLimit:  rateLimit.Status(),
+		Dispatcher: d.Status(),
+		Time:       now,
+	}
+	for _, job := range jobs {
+		org := OrgStatus{JobStatus: job.Status(), DataAgeSeconds: -1}
+		if !org.LastSuccessAt.IsZero() {
+			org.DataAgeSeconds = now.Sub(org.LastSuccessAt).Seconds()
+		}
+		g := NewGitHubCollector(job.orgName)
+		if repos, err := g.GetReposByOrg(); err == nil {
+			org.Repos = len(repos)
+			for _, repo := range repos {
+				if pulls, err := g.GetPullRequestsByRepo(repo.GetName()); err == nil {
+					org.PullRequests += len(pulls)
+				}
+				if issues, err := g.GetIssuesByRepo(repo.GetName()); err == nil {
+					org.Issues += len(issues)
+				}
+			}
+		}
+		s.Orgs = append(s.Orgs, org)
+	}
+	return s
+}
@@ -0,0 +1,80 @@
+package exporter
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v28/github"
+	"github.com/patrickmn/go-cache"
+)
+
+func TestCollectStatus(t *testing.T) {
+	setTestCache("ko-da-k")
+	defer Kv.Flush()
+	client, teardown := newTestClient(t, http.NotFoundHandler())
+	defer teardown()
+	job := NewJob(client, "ko-da-k")
+
+	if status := job.Status(); status.LastOutcome != "" || !status.LastStartedAt.IsZero() {
+		t.Errorf("unexpected status before execution: %+v", status)
+	}
+	if err := job.Execute(context.Background()); err == nil {
+		t.Fatalf("missing org should be an error")
+	}
+
+	s := CollectStatus([]*Job{job}, NewDispatcher(NewWorker()))
+	if len(s.Orgs) != 1 {
+		t.Fatalf("unexpected orgs: %+v", s.Orgs)
+	}
+	org := s.Orgs[0]
+	if org.Org != "ko-da-k" || org.Running || org.LastOutcome != outcomeFailure || org.LastError == "" {
+		t.Errorf("unexpected job status: %+v", org.JobStatus)
+	}
+	// last known good data are still cached
+	if org.Repos != 1 || org.PullRequests != 1 || org.Issues != 1 {
+		t.Errorf("unexpected counts: %+v", org)
+	}
+	if org.DataAgeSeconds != -1 {
+		t.Errorf("unexpected data age: got %v want %v", org.DataAgeSeconds, -1)
+	}
+	if s.Dispatcher.QueuedJobs != 0 || s.Dispatcher.Workers == 0 {
+		t.Errorf("unexpected dispatcher status: %+v", s.Dispatcher)
+	}
+}
+
+func TestJobStatusRedacted(t *testing.T) {
+	defaultPrivacy := privacy
+	defer func() { privacy = defaultPrivacy }()
+	privacy, _ = NewPrivacyPolicy("", "", "", "key", "", true)
+	secret := &github.Repository{Name: github.String("secret"), FullName: github.String("ko-da-k/secret"), Private: github.Bool(true)}
+	Kv.Set("ko-da-k-repos", []*github.Repository{secret}, cache.DefaultExpiration)
+	defer Kv.Flush()
+	job := NewJob(nil, "ko-da-k")
+
+	// errors of go-github have URLs with the raw name
+	u, _ := url.Parse("https://api.github.com/repos/ko-da-k/secret/pulls")
+	res := &http.Response{StatusCode: http.StatusInternalServerError, Request: &http.Request{Method: http.MethodGet, URL: u}}
+	errs := RepoErrors{"secret": &github.ErrorResponse{Response: res, Message: "Server Error"}}
+	job.recordRepoErrors(errs)
+	defer job.recordRepoErrors(nil)
+	job.status.start(time.Now())
+	job.status.finish(time.Now(), job.failedRepoReasons(), errs)
+
+	label := privacy.repoRef(secret).label
+	status := job.Status()
+	if want := "1 repositories failed: " + label + " (server_error)"; status.LastError != want {
+		t.Errorf("unexpected last error: got %q want %q", status.LastError, want)
+	}
+	b, err := json.Marshal(CollectStatus([]*Job{job}, NewDispatcher(NewWorker())))
+	if err != nil {
+		t.Fatalf("%+v\n", err)
+	}
+	if strings.Contains(string(b), "secret") {
+		t.Errorf("status should not have the redacted repository: %s", b)
+	}
+}
@@ -0,0 +1,358 @@
+package exporter
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	httpCacheHitsTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "github_exporter_http_cache_hits_total",
+			Help: "How many GitHub API responses were 304 Not Modified and served from the cache.",
+		},
+	)
+	httpCacheMissesTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "github_exporter_http_cache_misses_total",
+			Help: "How many GitHub API responses were downloaded.",
+		},
+	)
+	httpCacheEvictionsTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "github_exporter_http_cache_evictions_total",
+			Help: "How many cached GitHub API responses were evicted by size or TTL.",
+		},
+	)
+)
+
+// cachedResponse is a GET response which has ETag or Last-Modified
+type cachedResponse struct {
+	ETag         string
+	LastModified string
+	StatusCode   int
+	Header       http.Header
+	Body         []byte
+}
+
+type responseStore interface {
+	Get(key string) (*cachedResponse, bool)
+	Set(key string, res *cachedResponse)
+}
+
+// lruIndex evicts the least recently used entries over maxBytes
+// and entries unused for ttl, so the response cache does not grow for the life of the process.
+type lruIndex struct {
+	maxBytes int64
+	ttl      time.Duration
+	// onEvict is called without the lock held
+	onEvict func(key string)
+	now     func() time.Time
+
+	mu    sync.Mutex
+	ll    *list.List
+	items map[string]*list.Element
+	size  int64
+}
+
+type lruEntry struct {
+	key      string
+	size     int64
+	lastUsed time.Time
+	// value is nil in diskStore
+	value *cachedResponse
+}
+
+func newLRUIndex(maxBytes int64, ttl time.Duration, onEvict func(key string)) *lruIndex {
+	return &lruIndex{
+		maxBytes: maxBytes,
+		ttl:      ttl,
+		onEvict:  onEvict,
+		now:      time.Now,
+		ll:       list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+// get returns the entry and marks it used, or false if it is not found or expired
+func (c *lruIndex) get(key string) (*lruEntry, bool) {
+	c.mu.Lock()
+	e, ok := c.items[key]
+	if !ok {
+		c.mu.Unlock()
+		return nil, false
+	}
+	entry := e.Value.(*lruEntry)
+	now := c.now()
+	if c.ttl > 0 && now.Sub(entry.lastUsed) > c.ttl {
+		c.remove(e)
+		c.mu.Unlock()
+		c.evicted([]string{key})
+		return nil, false
+	}
+	entry.lastUsed = now
+	c.ll.MoveToFront(e)
+	c.mu.Unlock()
+	return entry, true
+}
+
+// add stores the entry as the most recently used one at lastUsed.
+// Entries are evicted from the least recently used one while the total exceeds maxBytes
+// or the oldest one is expired.
+func (c *lruIndex) add(key string, size int64, lastUsed time.Time, value *cachedResponse) {
+	c.mu.Lock()
+	if e, ok := c.items[key]; ok {
+		c.remove(e)
+	}
+	c.items[key] = c.ll.PushFront(&lruEntry{key: key, size: size, lastUsed: lastUsed, value: value})
+	c.size += size
+
+	var evicted []string
+	now := c.now()
+	for e := c.ll.Back(); e != nil; e = c.ll.Back() {
+		entry := e.Value.(*lruEntry)
+		expired := c.ttl > 0 && now.Sub(entry.lastUsed) > c.ttl
+		if !expired && (c.maxBytes <= 0 || c.size <= c.maxBytes) {
+			break
+		}
+		c.remove(e)
+		evicted = append(evicted, entry.key)
+	}
+	c.mu.Unlock()
+	c.evicted(evicted)
+}
+
+func (c *lruIndex) remove(e *list.Element) {
+	entry := e.Value.(*lruEntry)
+	c.ll.Remove(e)
+	delete(c.items, entry.key)
+	c.size -= entry.size
+}
+
+func (c *lruIndex) evicted(keys []string) {
+	if len(keys) == 0 {
+		return
+	}
+	httpCacheEvictionsTotal.Add(float64(len(keys)))
+	if c.onEvict != nil {
+		for _, key := range keys {
+			c.onEvict(key)
+		}
+	}
+}
+
+// responseSize approximates memory of the response
+func responseSize(key string, res *cachedResponse) int64 {
+	size := len(key) + len(res.Body) + len(res.ETag) + len(res.LastModified)
+	for k, vs := range res.Header {
+		size += len(k)
+		for _, v := range vs {
+			size += len(v)
+		}
+	}
+	return int64(size)
+}
+
+// memoryStore keeps responses in memory
+type memoryStore struct {
+	index *lruIndex
+}
+
+func newMemoryStore(maxBytes int64, ttl time.Duration) *memoryStore {
+	return &memoryStore{newLRUIndex(maxBytes, ttl, nil)}
+}
+
+func (s *memoryStore) Get(key string) (*cachedResponse, bool) {
+	entry, ok := s.index.get(key)
+	if !ok {
+		return nil, false
+	}
+	return entry.value, true
+}
+
+func (s *memoryStore) Set(key string, res *cachedResponse) {
+	s.index.add(key, responseSize(key, res), s.index.now(), res)
+}
+
+// diskFilePattern matches files written by diskStore, so other files in the directory are left alone
+var diskFilePattern = regexp.MustCompile(`^[0-9a-f]{64}\.json(\.tmp)?$`)
+
+// diskStore keeps responses in files of the directory to survive restart.
+// The index of the files is kept in memory to evict them.
+type diskStore struct {
+	dir   string
+	index *lruIndex
+}
+
+func newDiskStore(dir string, maxBytes int64, ttl time.Duration) (*diskStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	s := &diskStore{dir: dir}
+	s.index = newLRUIndex(maxBytes, ttl, func(path string) {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			log.Warnf("failed to remove cached response: %v", err)
+		}
+	})
+
+	// files of the previous process, which were used last at their modification time
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].ModTime().Before(files[j].ModTime()) })
+	for _, f := range files {
+		path := filepath.Join(dir, f.Name())
+		if f.IsDir() || !diskFilePattern.MatchString(f.Name()) {
+			continue
+		}
+		if filepath.Ext(f.Name()) == ".tmp" {
+			// partial file of a crash while writing
+			os.Remove(path)
+			continue
+		}
+		s.index.add(path, f.Size(), f.ModTime(), nil)
+	}
+	return s, nil
+}
+
+func (s *diskStore) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(s.dir, hex.EncodeToString(sum[:])+".json")
+}
+
+func (s *diskStore) Get(key string) (*cachedResponse, bool) {
+	path := s.path(key)
+	if _, ok := s.index.get(path); !ok {
+		return nil, false
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	res := &cachedResponse{}
+	if err := json.Unmarshal(b, res); err != nil {
+		return nil, false
+	}
+	// keep the last use for the next process
+	now := time.Now()
+	os.Chtimes(path, now, now)
+	return res, true
+}
+
+func (s *diskStore) Set(key string, res *cachedResponse) {
+	b, err := json.Marshal(res)
+	if err != nil {
+		log.Warnf("failed to encode cached response: %v", err)
+		return
+	}
+	// write and rename not to read a partial file
+	path := s.path(key)
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, b, 0600); err != nil {
+		log.Warnf("failed to write cached response: %v", err)
+		return
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		log.Warnf("failed to write cached response: %v", err)
+		return
+	}
+	s.index.add(path, int64(len(b)), s.index.now(), nil)
+}
+
+// cachingTransport sends conditional requests with ETag or Last-Modified of the previous response.
+// 304 Not Modified, which does not count against the rate limit, is served from the stored response.
+// Each page of list endpoints is stored separately because the key includes the query.
+type cachingTransport struct {
+	base  http.RoundTripper
+	store responseStore
+}
+
+func newCachingTransport(base http.RoundTripper, store responseStore) *cachingTransport {
+	return &cachingTransport{base, store}
+}
+
+// cacheKey includes Accept header because go-github sets preview media types per endpoint
+func cacheKey(req *http.Request) string {
+	return req.URL.String() + " " + req.Header.Get("Accept")
+}
+
+func (t *cachingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// the caller manages conditional requests by itself
+	if req.Method != http.MethodGet || req.Header.Get("If-None-Match") != "" || req.Header.Get("If-Modified-Since") != "" {
+		return t.base.RoundTrip(req)
+	}
+
+	key := cacheKey(req)
+	cached, found := t.store.Get(key)
+	if found {
+		req = req.Clone(req.Context())
+		if cached.ETag != "" {
+			req.Header.Set("If-None-Match", cached.ETag)
+		}
+		if cached.LastModified != "" {
+			req.Header.Set("If-Modified-Since", cached.LastModified)
+		}
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if found && resp.StatusCode == http.StatusNotModified {
+		httpCacheHitsTotal.Inc()
+		resp.Body.Close()
+		// 304 has the latest headers such as rate limit
+		header := cached.Header.Clone()
+		for k, v := range resp.Header {
+			header[k] = v
+		}
+		return &http.Response{
+			Status:        http.StatusText(cached.StatusCode),
+			StatusCode:    cached.StatusCode,
+			Proto:         resp.Proto,
+			ProtoMajor:    resp.ProtoMajor,
+			ProtoMinor:    resp.ProtoMinor,
+			Header:        header,
+			Body:          ioutil.NopCloser(bytes.NewReader(cached.Body)),
+			ContentLength: int64(len(cached.Body)),
+			Request:       req,
+		}, nil
+	}
+	httpCacheMissesTotal.Inc()
+
+	etag := resp.Header.Get("ETag")
+	lastModified := resp.Header.Get("Last-Modified")
+	if resp.StatusCode != http.StatusOK || (etag == "" && lastModified == "") {
+		return resp, nil
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	t.store.Set(key, &cachedResponse{
+		ETag:         etag,
+		LastModified: lastModified,
+		StatusCode:   resp.StatusCode,
+		Header:       resp.Header.Clone(),
+		Body:         body,
+	})
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return resp, nil
+}
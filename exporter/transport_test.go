@@ -0,0 +1,165 @@
+package exporter
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v28/github"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestCachingTransport(t *testing.T) {
+	downloads := 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("/orgs/ko-da-k/repos", func(w http.ResponseWriter, r *http.Request) {
+		page := r.URL.Query().Get("page")
+		etag := fmt.Sprintf(`"page-%s"`, page)
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		downloads++
+		w.Header().Set("ETag", etag)
+		if page != "2" {
+			w.Header().Set("Link", fmt.Sprintf(`<http://%s/orgs/ko-da-k/repos?page=2>; rel="next"`, r.Host))
+			fmt.Fprint(w, `[{"id":1,"name":"hoge"}]`)
+			return
+		}
+		fmt.Fprint(w, `[{"id":2,"name":"fuga"}]`)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client := github.NewClient(&http.Client{
+		Transport: newCachingTransport(http.DefaultTransport, newMemoryStore(1<<20, time.Hour)),
+	})
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+
+	hits := testutil.ToFloat64(httpCacheHitsTotal)
+	for i := 0; i < 2; i++ {
+		opt := &github.RepositoryListByOrgOptions{}
+		var names []string
+		for {
+			repos, resp, err := client.Repositories.ListByOrg(context.Background(), "ko-da-k", opt)
+			if err != nil {
+				t.Fatalf("%+v\n", err)
+			}
+			for _, repo := range repos {
+				names = append(names, repo.GetName())
+			}
+			if resp.NextPage == 0 {
+				break
+			}
+			opt.Page = resp.NextPage
+		}
+		if len(names) != 2 || names[0] != "hoge" || names[1] != "fuga" {
+			t.Errorf("unexpected repositories: got %v want [hoge fuga]", names)
+		}
+	}
+
+	if downloads != 2 {
+		t.Errorf("unexpected downloads: got %v want %v", downloads, 2)
+	}
+	if got := testutil.ToFloat64(httpCacheHitsTotal) - hits; got != 2 {
+		t.Errorf("unexpected cache hits: got %v want %v", got, 2)
+	}
+}
+
+func TestMemoryStoreEviction(t *testing.T) {
+	s := newMemoryStore(250, time.Hour)
+	now := time.Now()
+	s.index.now = func() time.Time { return now }
+	res := func() *cachedResponse { return &cachedResponse{Body: make([]byte, 100)} }
+
+	s.Set("a", res())
+	s.Set("b", res())
+	// a is used more recently than b
+	s.Get("a")
+	s.Set("c", res())
+	if _, ok := s.Get("b"); ok {
+		t.Errorf("the least recently used response should be evicted over max bytes")
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, ok := s.Get(key); !ok {
+			t.Errorf("%s should be kept", key)
+		}
+	}
+
+	now = now.Add(2 * time.Hour)
+	if _, ok := s.Get("a"); ok {
+		t.Errorf("response unused for ttl should be expired")
+	}
+	s.Set("d", res())
+	if s.index.ll.Len() != 1 || s.index.size != responseSize("d", res()) {
+		t.Errorf("expired responses should be evicted: got %v entries of %v bytes", s.index.ll.Len(), s.index.size)
+	}
+}
+
+func TestDiskStoreEviction(t *testing.T) {
+	dir := t.TempDir()
+	s, err := newDiskStore(dir, 500, time.Hour)
+	if err != nil {
+		t.Fatalf("%+v\n", err)
+	}
+	res := &cachedResponse{ETag: `"1"`, StatusCode: http.StatusOK, Body: make([]byte, 100)}
+	for _, key := range []string{"a", "b", "c"} {
+		s.Set(key, res)
+	}
+	if _, ok := s.Get("a"); ok {
+		t.Errorf("the least recently used response should be evicted over max bytes")
+	}
+	if _, err := os.Stat(s.path("a")); !os.IsNotExist(err) {
+		t.Errorf("file of the evicted response should be removed: %v", err)
+	}
+
+	// files of the previous process are indexed
+	s, err = newDiskStore(dir, 500, time.Hour)
+	if err != nil {
+		t.Fatalf("%+v\n", err)
+	}
+	if cached, ok := s.Get("c"); !ok || cached.ETag != `"1"` {
+		t.Errorf("response of the previous process should be served: got %v", cached)
+	}
+}
+
+func TestDiskStoreForeignFiles(t *testing.T) {
+	dir := t.TempDir()
+	s, err := newDiskStore(dir, 500, time.Hour)
+	if err != nil {
+		t.Fatalf("%+v\n", err)
+	}
+	res := &cachedResponse{ETag: `"1"`, StatusCode: http.StatusOK, Body: make([]byte, 100)}
+	s.Set("a", res)
+	partial := s.path("b") + ".tmp"
+	foreign := []string{filepath.Join(dir, "notes.txt"), filepath.Join(dir, "other.json")}
+	for _, path := range append(foreign, partial) {
+		if err := ioutil.WriteFile(path, make([]byte, 1000), 0600); err != nil {
+			t.Fatalf("%+v\n", err)
+		}
+	}
+
+	// only files of the store are indexed and cleaned up
+	s, err = newDiskStore(dir, 500, time.Hour)
+	if err != nil {
+		t.Fatalf("%+v\n", err)
+	}
+	if _, ok := s.Get("a"); !ok {
+		t.Errorf("response of the previous process should be served")
+	}
+	if _, err := os.Stat(partial); !os.IsNotExist(err) {
+		t.Errorf("partial file should be removed: %v", err)
+	}
+	for _, path := range foreign {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("file not written by the store should be kept: %v", err)
+		}
+	}
+}
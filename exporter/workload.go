@@ -0,0 +1,82 @@
+package exporter
+
+import (
+	"sort"
+
+	"github.com/google/go-github/v28/github"
+)
+
+// workload counts open tasks of each user in the organization
+type workload struct {
+	// key is user login
+	openPullRequests map[string]int
+	pendingReviews   map[string]int
+	assignedIssues   map[string]int
+	// key is team slug. review requested to the team itself, not to the members.
+	pendingTeamReviews map[string]int
+}
+
+func newWorkload() *workload {
+	return &workload{
+		openPullRequests:   make(map[string]int),
+		pendingReviews:     make(map[string]int),
+		assignedIssues:     make(map[string]int),
+		pendingTeamReviews: make(map[string]int),
+	}
+}
+
+func (w *workload) addPullRequest(pull *github.PullRequest) {
+	if pull.GetState() != "open" {
+		return
+	}
+	w.openPullRequests[pull.GetUser().GetLogin()]++
+	for _, reviewer := range pull.RequestedReviewers {
+		w.pendingReviews[reviewer.GetLogin()]++
+	}
+	for _, team := range pull.RequestedTeams {
+		w.pendingTeamReviews[team.GetSlug()]++
+	}
+}
+
+func (w *workload) addIssue(issue *github.Issue) {
+	if issue.GetState() != "open" {
+		return
+	}
+	assignees := issue.Assignees
+	if len(assignees) == 0 && issue.Assignee != nil {
+		assignees = []*github.User{issue.Assignee}
+	}
+	for _, assignee := range assignees {
+		w.assignedIssues[assignee.GetLogin()]++
+	}
+}
+
+// logins returns all users who have any workload, sorted by login
+func (w *workload) logins() []string {
+	set := make(map[string]struct{})
+	for _, m := range []map[string]int{w.openPullRequests, w.pendingReviews, w.assignedIssues} {
+		for login := range m {
+			set[login] = struct{}{}
+		}
+	}
+	logins := make([]string, 0, len(set))
+	for login := range set {
+		logins = append(logins, login)
+	}
+	sort.Strings(logins)
+	return logins
+}
+
+// teamsByLogin inverts team members to the teams each user belongs to.
+func teamsByLogin(members map[string][]*github.User) map[string][]string {
+	teams := make(map[string][]string)
+	for slug, users := range members {
+		for _, user := range users {
+			teams[user.GetLogin()] = append(teams[user.GetLogin()], slug)
+		}
+	}
+	for login := range teams {
+		sort.Strings(teams[login])
+	}
+	return teams
+}
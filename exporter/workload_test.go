@@ -0,0 +1,65 @@
+package exporter
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/google/go-github/v28/github"
+)
+
+func TestWorkload(t *testing.T) {
+	alice := &github.User{Login: github.String("alice")}
+	bob := &github.User{Login: github.String("bob")}
+
+	w := newWorkload()
+	w.addPullRequest(&github.PullRequest{
+		State:              github.String("open"),
+		User:               alice,
+		RequestedReviewers: []*github.User{bob},
+		RequestedTeams:     []*github.Team{{Slug: github.String("backend")}},
+	})
+	w.addPullRequest(&github.PullRequest{
+		State:              github.String("closed"),
+		User:               alice,
+		RequestedReviewers: []*github.User{bob},
+	})
+	w.addIssue(&github.Issue{
+		State:     github.String("open"),
+		Assignees: []*github.User{alice, bob},
+	})
+	w.addIssue(&github.Issue{
+		State:    github.String("open"),
+		Assignee: bob,
+	})
+
+	if got := w.openPullRequests["alice"]; got != 1 {
+		t.Errorf("unexpected open pull requests: got %v want %v", got, 1)
+	}
+	if got := w.pendingReviews["bob"]; got != 1 {
+		t.Errorf("unexpected pending reviews: got %v want %v", got, 1)
+	}
+	if got := w.pendingTeamReviews["backend"]; got != 1 {
+		t.Errorf("unexpected pending team reviews: got %v want %v", got, 1)
+	}
+	if got := w.assignedIssues["bob"]; got != 2 {
+		t.Errorf("unexpected assigned issues: got %v want %v", got, 2)
+	}
+	if got, want := w.logins(), []string{"alice", "bob"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected logins: got %v want %v", got, want)
+	}
+}
+
+func TestTeamsByLogin(t *testing.T) {
+	members := map[string][]*github.User{
+		"backend":  {{Login: github.String("alice")}, {Login: github.String("bob")}},
+		"frontend": {{Login: github.String("alice")}},
+	}
+	teams := teamsByLogin(members)
+
+	if got, want := teams["alice"], []string{"backend", "frontend"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected teams: got %v want %v", got, want)
+	}
+	if got, want := teams["bob"], []string{"backend"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected teams: got %v want %v", got, want)
+	}
+}
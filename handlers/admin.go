@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/ko-da-k/github-developer-exporter/exporter"
+)
+
+// AdminHandler lets operators refresh, pause, resume and purge jobs
+type AdminHandler struct {
+	scheduler *exporter.Scheduler
+	// token is required as bearer token if it is not empty
+	token  []byte
+	router *mux.Router
+}
+
+func NewAdminHandler(scheduler *exporter.Scheduler, token string) http.Handler {
+	h := &AdminHandler{scheduler: scheduler, token: []byte(token)}
+
+	r := mux.NewRouter().StrictSlash(true)
+	r.HandleFunc("/admin/refresh", h.refresh).Methods(http.MethodPost)
+	r.HandleFunc("/admin/pause", h.pause).Methods(http.MethodPost)
+	r.HandleFunc("/admin/resume", h.resume).Methods(http.MethodPost)
+	r.HandleFunc("/admin/cache", h.purge).Methods(http.MethodDelete)
+	r.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeAPIError(w, http.StatusNotFound, "not found")
+	})
+	r.MethodNotAllowedHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed")
+	})
+	h.router = r
+	return h
+}
+
+func (h *AdminHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if len(h.token) > 0 && !validBearerToken(r.Header.Get("Authorization"), h.token) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+		writeAPIError(w, http.StatusUnauthorized, http.StatusText(http.StatusUnauthorized))
+		return
+	}
+	h.router.ServeHTTP(w, r)
+}
+
+func validBearerToken(authorization string, token []byte) bool {
+	if !strings.HasPrefix(authorization, "Bearer ") {
+		return false
+	}
+	actual := []byte(strings.TrimPrefix(authorization, "Bearer "))
+	return subtle.ConstantTimeCompare(actual, token) == 1
+}
+
+// refresh adds jobs of `org`, or all orgs if it is not given
+func (h *AdminHandler) refresh(w http.ResponseWriter, r *http.Request) {
+	org := r.URL.Query().Get("org")
+	queued, err := h.scheduler.Refresh(org)
+	if err != nil {
+		writeSchedulerError(w, err)
+		return
+	}
+	log.Infof("refresh requested: %v", queued)
+	writeJSON(w, http.StatusAccepted, map[string]interface{}{"queued": queued})
+}
+
+func (h *AdminHandler) pause(w http.ResponseWriter, r *http.Request) {
+	h.scheduler.Pause()
+	log.Infof("scheduler paused")
+	writeJSON(w, http.StatusOK, map[string]interface{}{"paused": true})
+}
+
+func (h *AdminHandler) resume(w http.ResponseWriter, r *http.Request) {
+	h.scheduler.Resume()
+	log.Infof("scheduler resumed")
+	writeJSON(w, http.StatusOK, map[string]interface{}{"paused": false})
+}
+
+// purge deletes cached data of `org`, or all orgs if it is not given
+func (h *AdminHandler) purge(w http.ResponseWriter, r *http.Request) {
+	org := r.URL.Query().Get("org")
+	purged, err := h.scheduler.Purge(org)
+	if err != nil {
+		writeSchedulerError(w, err)
+		return
+	}
+	log.Infof("cache purged: %v", purged)
+	writeJSON(w, http.StatusOK, map[string]interface{}{"purged": purged})
+}
+
+func writeSchedulerError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, exporter.ErrUnknownOrg):
+		writeAPIError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, exporter.ErrQueueFull):
+		writeAPIError(w, http.StatusServiceUnavailable, err.Error())
+	default:
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Warnf("failed to write json: %v", err)
+	}
+}
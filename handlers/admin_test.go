@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ko-da-k/github-developer-exporter/exporter"
+)
+
+func TestAdminHandler(t *testing.T) {
+	scheduler := exporter.NewScheduler(
+		exporter.NewDispatcher(exporter.NewWorker()),
+		[]*exporter.Job{exporter.NewJob(nil, "ko-da-k")},
+		time.Minute,
+	)
+	testHandler := NewAdminHandler(scheduler, "token")
+
+	cases := []struct {
+		method string
+		target string
+		token  string
+		status int
+	}{
+		{"POST", "/admin/refresh?org=ko-da-k", "wrong", http.StatusUnauthorized},
+		{"POST", "/admin/refresh?org=ko-da-k", "", http.StatusUnauthorized},
+		{"POST", "/admin/refresh?org=ko-da-k", "token", http.StatusAccepted},
+		{"POST", "/admin/refresh?org=unknown", "token", http.StatusNotFound},
+		{"GET", "/admin/refresh", "token", http.StatusMethodNotAllowed},
+		{"POST", "/admin/pause", "token", http.StatusOK},
+		{"POST", "/admin/resume", "token", http.StatusOK},
+		{"DELETE", "/admin/cache?org=ko-da-k", "token", http.StatusOK},
+		{"DELETE", "/admin/cache?org=unknown", "token", http.StatusNotFound},
+	}
+	for _, c := range cases {
+		req, err := http.NewRequest(c.method, c.target, nil)
+		if err != nil {
+			t.Fatalf("%+v\n", err)
+		}
+		if c.token != "" {
+			req.Header.Set("Authorization", "Bearer "+c.token)
+		}
+		testRecorder := httptest.NewRecorder()
+		testHandler.ServeHTTP(testRecorder, req)
+
+		if status := testRecorder.Code; status != c.status {
+			t.Errorf("%s %s: handler returned wrong status code: got %v want %v",
+				c.method, c.target, status, c.status)
+		}
+	}
+}
@@ -0,0 +1,500 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v28/github"
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/ko-da-k/github-developer-exporter/exporter"
+)
+
+const (
+	defaultPerPage = 30
+	maxPerPage     = 100
+)
+
+// APIHandler serves cached organizations, repositories, pull requests and issues
+// as JSON, or CSV if `?format=csv` or `Accept: text/csv` is given.
+type APIHandler struct {
+	gs     map[string]*exporter.GitHubCollector
+	orgs   []string
+	router *mux.Router
+}
+
+func NewAPIHandler(gs []*exporter.GitHubCollector) http.Handler {
+	h := &APIHandler{gs: make(map[string]*exporter.GitHubCollector, len(gs))}
+	for _, g := range gs {
+		h.gs[g.Org()] = g
+		h.orgs = append(h.orgs, g.Org())
+	}
+
+	r := mux.NewRouter().StrictSlash(true)
+	r.HandleFunc("/api/v1/orgs", h.listOrgs).Methods(http.MethodGet)
+	r.HandleFunc("/api/v1/orgs/{org}/repos", h.listRepos).Methods(http.MethodGet)
+	r.HandleFunc("/api/v1/orgs/{org}/repos/{repo}/pulls", h.listPullRequests).Methods(http.MethodGet)
+	r.HandleFunc("/api/v1/orgs/{org}/repos/{repo}/issues", h.listIssues).Methods(http.MethodGet)
+	r.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeAPIError(w, http.StatusNotFound, "not found")
+	})
+	r.MethodNotAllowedHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed")
+	})
+	h.router = r
+	return h
+}
+
+func (h *APIHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.router.ServeHTTP(w, r)
+}
+
+// apiRecord is a row of JSON array and CSV
+type apiRecord interface {
+	csvRecord() []string
+}
+
+type orgRecord struct {
+	Login             string `json:"login"`
+	Name              string `json:"name"`
+	URL               string `json:"html_url"`
+	PublicRepos       int    `json:"public_repos"`
+	TotalPrivateRepos int    `json:"total_private_repos"`
+	// Cached is false until the first job of the org finishes
+	Cached bool `json:"cached"`
+}
+
+var orgHeader = []string{"login", "name", "html_url", "public_repos", "total_private_repos", "cached"}
+
+func (o *orgRecord) csvRecord() []string {
+	return []string{
+		o.Login,
+		o.Name,
+		o.URL,
+		strconv.Itoa(o.PublicRepos),
+		strconv.Itoa(o.TotalPrivateRepos),
+		strconv.FormatBool(o.Cached),
+	}
+}
+
+type repoRecord struct {
+	Name            string    `json:"name"`
+	FullName        string    `json:"full_name"`
+	Private         bool      `json:"private"`
+	Archived        bool      `json:"archived"`
+	URL             string    `json:"html_url"`
+	DefaultBranch   string    `json:"default_branch"`
+	OpenIssuesCount int       `json:"open_issues_count"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+	PushedAt        time.Time `json:"pushed_at"`
+}
+
+var repoHeader = []string{
+	"name", "full_name", "private", "archived", "html_url", "default_branch",
+	"open_issues_count", "created_at", "updated_at", "pushed_at",
+}
+
+func (r *repoRecord) csvRecord() []string {
+	return []string{
+		r.Name,
+		r.FullName,
+		strconv.FormatBool(r.Private),
+		strconv.FormatBool(r.Archived),
+		r.URL,
+		r.DefaultBranch,
+		strconv.Itoa(r.OpenIssuesCount),
+		formatTime(r.CreatedAt),
+		formatTime(r.UpdatedAt),
+		formatTime(r.PushedAt),
+	}
+}
+
+// itemRecord is a pull request or an issue
+type itemRecord struct {
+	Number    int        `json:"number"`
+	Title     string     `json:"title"`
+	State     string     `json:"state"`
+	User      string     `json:"user"`
+	Assignees []string   `json:"assignees"`
+	Labels    []string   `json:"labels"`
+	URL       string     `json:"html_url"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	ClosedAt  *time.Time `json:"closed_at"`
+}
+
+var itemHeader = []string{
+	"number", "title", "state", "user", "assignees", "labels", "html_url",
+	"created_at", "updated_at", "closed_at",
+}
+
+func (i *itemRecord) csvRecord() []string {
+	closedAt := ""
+	if i.ClosedAt != nil {
+		closedAt = formatTime(*i.ClosedAt)
+	}
+	return []string{
+		strconv.Itoa(i.Number),
+		i.Title,
+		i.State,
+		i.User,
+		strings.Join(i.Assignees, ";"),
+		strings.Join(i.Labels, ";"),
+		i.URL,
+		formatTime(i.CreatedAt),
+		formatTime(i.UpdatedAt),
+		closedAt,
+	}
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+func logins(users []*github.User) []string {
+	ret := make([]string, 0, len(users))
+	for _, u := range users {
+		ret = append(ret, u.GetLogin())
+	}
+	return ret
+}
+
+func newPullRequestRecord(pull *github.PullRequest) *itemRecord {
+	labels := make([]string, 0, len(pull.Labels))
+	for _, l := range pull.Labels {
+		labels = append(labels, l.GetName())
+	}
+	state := pull.GetState()
+	if pull.MergedAt != nil {
+		state = "merged"
+	}
+	return &itemRecord{
+		Number:    pull.GetNumber(),
+		Title:     pull.GetTitle(),
+		State:     state,
+		User:      pull.GetUser().GetLogin(),
+		Assignees: logins(pull.Assignees),
+		Labels:    labels,
+		URL:       pull.GetHTMLURL(),
+		CreatedAt: pull.GetCreatedAt(),
+		UpdatedAt: pull.GetUpdatedAt(),
+		ClosedAt:  pull.ClosedAt,
+	}
+}
+
+func newIssueRecord(issue *github.Issue) *itemRecord {
+	labels := make([]string, 0, len(issue.Labels))
+	for _, l := range issue.Labels {
+		labels = append(labels, l.GetName())
+	}
+	return &itemRecord{
+		Number:    issue.GetNumber(),
+		Title:     issue.GetTitle(),
+		State:     issue.GetState(),
+		User:      issue.GetUser().GetLogin(),
+		Assignees: logins(issue.Assignees),
+		Labels:    labels,
+		URL:       issue.GetHTMLURL(),
+		CreatedAt: issue.GetCreatedAt(),
+		UpdatedAt: issue.GetUpdatedAt(),
+		ClosedAt:  issue.ClosedAt,
+	}
+}
+
+func (h *APIHandler) listOrgs(w http.ResponseWriter, r *http.Request) {
+	records := make([]apiRecord, 0, len(h.orgs))
+	for _, name := range h.orgs {
+		record := &orgRecord{Login: name}
+		if org, err := h.gs[name].GetOrg(); err == nil {
+			record.Name = org.GetName()
+			record.URL = org.GetHTMLURL()
+			record.PublicRepos = org.GetPublicRepos()
+			record.TotalPrivateRepos = org.GetTotalPrivateRepos()
+			record.Cached = true
+		}
+		records = append(records, record)
+	}
+	writeRecords(w, r, orgHeader, records)
+}
+
+func (h *APIHandler) listRepos(w http.ResponseWriter, r *http.Request) {
+	g, ok := h.collector(w, r)
+	if !ok {
+		return
+	}
+	repos, err := g.GetReposByOrg()
+	if err != nil {
+		writeCacheError(w, err)
+		return
+	}
+	records := make([]apiRecord, 0, len(repos))
+	for _, repo := range repos {
+		records = append(records, &repoRecord{
+			Name:            repo.GetName(),
+			FullName:        repo.GetFullName(),
+			Private:         repo.GetPrivate(),
+			Archived:        repo.GetArchived(),
+			URL:             repo.GetHTMLURL(),
+			DefaultBranch:   repo.GetDefaultBranch(),
+			OpenIssuesCount: repo.GetOpenIssuesCount(),
+			CreatedAt:       repo.GetCreatedAt().Time,
+			UpdatedAt:       repo.GetUpdatedAt().Time,
+			PushedAt:        repo.GetPushedAt().Time,
+		})
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].(*repoRecord).Name < records[j].(*repoRecord).Name
+	})
+	records, ok = paginate(w, r, records)
+	if !ok {
+		return
+	}
+	writeRecords(w, r, repoHeader, records)
+}
+
+func (h *APIHandler) listPullRequests(w http.ResponseWriter, r *http.Request) {
+	g, ok := h.collector(w, r)
+	if !ok {
+		return
+	}
+	pulls, err := g.GetPullRequestsByRepo(mux.Vars(r)["repo"])
+	if err != nil {
+		writeCacheError(w, err)
+		return
+	}
+	items := make([]*itemRecord, 0, len(pulls))
+	for _, pull := range pulls {
+		items = append(items, newPullRequestRecord(pull))
+	}
+	h.writeItems(w, r, items)
+}
+
+func (h *APIHandler) listIssues(w http.ResponseWriter, r *http.Request) {
+	g, ok := h.collector(w, r)
+	if !ok {
+		return
+	}
+	issues, err := g.GetIssuesByRepo(mux.Vars(r)["repo"])
+	if err != nil {
+		writeCacheError(w, err)
+		return
+	}
+	items := make([]*itemRecord, 0, len(issues))
+	for _, issue := range issues {
+		items = append(items, newIssueRecord(issue))
+	}
+	h.writeItems(w, r, items)
+}
+
+// writeItems filters, sorts and paginates pull requests or issues
+func (h *APIHandler) writeItems(w http.ResponseWriter, r *http.Request, items []*itemRecord) {
+	filter, err := newItemFilter(r.URL.Query())
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	records := make([]apiRecord, 0, len(items))
+	for _, item := range items {
+		if filter.match(item) {
+			records = append(records, item)
+		}
+	}
+	// newest first like GitHub API
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].(*itemRecord).Number > records[j].(*itemRecord).Number
+	})
+	records, ok := paginate(w, r, records)
+	if !ok {
+		return
+	}
+	writeRecords(w, r, itemHeader, records)
+}
+
+// collector returns the collector of the org in the path if it is configured
+func (h *APIHandler) collector(w http.ResponseWriter, r *http.Request) (*exporter.GitHubCollector, bool) {
+	org := mux.Vars(r)["org"]
+	g, ok := h.gs[org]
+	if !ok {
+		writeAPIError(w, http.StatusNotFound, fmt.Sprintf("org %s is not configured", org))
+	}
+	return g, ok
+}
+
+// itemFilter filters pull requests and issues by query parameters
+type itemFilter struct {
+	state    string
+	assignee string
+	labels   []string
+	// since and until are compared with updated_at like `since` of GitHub API
+	since time.Time
+	until time.Time
+}
+
+func newItemFilter(q url.Values) (*itemFilter, error) {
+	f := &itemFilter{
+		state:    q.Get("state"),
+		assignee: q.Get("assignee"),
+		labels:   q["label"],
+	}
+	var err error
+	if f.since, err = parseDate(q.Get("since")); err != nil {
+		return nil, fmt.Errorf("invalid since: %w", err)
+	}
+	if f.until, err = parseDate(q.Get("until")); err != nil {
+		return nil, fmt.Errorf("invalid until: %w", err)
+	}
+	return f, nil
+}
+
+// parseDate parses RFC3339 or YYYY-MM-DD
+func parseDate(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", s)
+}
+
+func (f *itemFilter) match(item *itemRecord) bool {
+	switch f.state {
+	case "", "all":
+	case "closed":
+		// merged pull requests are also closed
+		if item.State != "closed" && item.State != "merged" {
+			return false
+		}
+	default:
+		if item.State != f.state {
+			return false
+		}
+	}
+	if f.assignee != "" && !contains(item.Assignees, f.assignee) {
+		return false
+	}
+	for _, l := range f.labels {
+		if !contains(item.Labels, l) {
+			return false
+		}
+	}
+	if !f.since.IsZero() && item.UpdatedAt.Before(f.since) {
+		return false
+	}
+	if !f.until.IsZero() && item.UpdatedAt.After(f.until) {
+		return false
+	}
+	return true
+}
+
+func contains(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// paginate returns the page by `page` and `per_page` like GitHub API.
+// X-Total-Count and Link headers are set.
+func paginate(w http.ResponseWriter, r *http.Request, records []apiRecord) ([]apiRecord, bool) {
+	q := r.URL.Query()
+	page, perPage := 1, defaultPerPage
+	var err error
+	if s := q.Get("page"); s != "" {
+		if page, err = strconv.Atoi(s); err != nil || page < 1 {
+			writeAPIError(w, http.StatusBadRequest, "page must be a positive integer")
+			return nil, false
+		}
+	}
+	if s := q.Get("per_page"); s != "" {
+		if perPage, err = strconv.Atoi(s); err != nil || perPage < 1 || perPage > maxPerPage {
+			writeAPIError(w, http.StatusBadRequest, fmt.Sprintf("per_page must be from 1 to %d", maxPerPage))
+			return nil, false
+		}
+	}
+
+	total := len(records)
+	// compare page with the number of pages not to overflow by page*perPage
+	pages := (total + perPage - 1) / perPage
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
+	var links []string
+	link := func(p int, rel string) {
+		q.Set("page", strconv.Itoa(p))
+		q.Set("per_page", strconv.Itoa(perPage))
+		links = append(links, fmt.Sprintf(`<%s?%s>; rel="%s"`, r.URL.Path, q.Encode(), rel))
+	}
+	if page < pages {
+		link(page+1, "next")
+	}
+	if page > 1 {
+		link(page-1, "prev")
+	}
+	if len(links) > 0 {
+		w.Header().Set("Link", strings.Join(links, ", "))
+	}
+
+	start := total
+	if page <= pages {
+		start = (page - 1) * perPage
+	}
+	end := start + perPage
+	if end > total {
+		end = total
+	}
+	return records[start:end], true
+}
+
+func wantsCSV(r *http.Request) bool {
+	switch r.URL.Query().Get("format") {
+	case "csv":
+		return true
+	case "json":
+		return false
+	}
+	return strings.Contains(r.Header.Get("Accept"), "text/csv")
+}
+
+func writeRecords(w http.ResponseWriter, r *http.Request, header []string, records []apiRecord) {
+	if wantsCSV(r) {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		cw := csv.NewWriter(w)
+		cw.Write(header)
+		for _, record := range records {
+			cw.Write(record.csvRecord())
+		}
+		cw.Flush()
+		if err := cw.Error(); err != nil {
+			log.Warnf("failed to write csv: %v", err)
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(records); err != nil {
+		log.Warnf("failed to write json: %v", err)
+	}
+}
+
+// writeCacheError means the job of the org or the repository has not finished yet
+func writeCacheError(w http.ResponseWriter, err error) {
+	writeAPIError(w, http.StatusNotFound, err.Error())
+}
+
+func writeAPIError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v28/github"
+	"github.com/patrickmn/go-cache"
+
+	"github.com/ko-da-k/github-developer-exporter/exporter"
+)
+
+func setAPICache() {
+	updated := time.Date(2020, 1, 10, 0, 0, 0, 0, time.UTC)
+	merged := updated
+	alice := &github.User{Login: github.String("alice")}
+	exporter.Kv.Set("ko-da-k", &github.Organization{Login: github.String("ko-da-k"), Name: github.String("ko-da-k")}, cache.DefaultExpiration)
+	exporter.Kv.Set("ko-da-k-repos", []*github.Repository{
+		{Name: github.String("hoge")},
+	}, cache.DefaultExpiration)
+	exporter.Kv.Set("ko-da-k-hoge-pulls", []*github.PullRequest{
+		{Number: github.Int(1), State: github.String("closed"), MergedAt: &merged, UpdatedAt: &updated},
+		{Number: github.Int(2), State: github.String("open"), UpdatedAt: &updated, Assignees: []*github.User{alice},
+			Labels: []*github.Label{{Name: github.String("bug")}}},
+		{Number: github.Int(3), State: github.String("open"), UpdatedAt: &updated, Title: github.String("a, \"quoted\" title")},
+	}, cache.DefaultExpiration)
+	exporter.Kv.Set("ko-da-k-hoge-issues", []*github.Issue{
+		{Number: github.Int(4), State: github.String("open"), UpdatedAt: &updated,
+			Labels: []github.Label{{Name: github.String("bug")}}},
+	}, cache.DefaultExpiration)
+}
+
+func serveAPI(t *testing.T, target string, header map[string]string) *httptest.ResponseRecorder {
+	testHandler := NewAPIHandler([]*exporter.GitHubCollector{
+		exporter.NewGitHubCollector("ko-da-k"),
+		exporter.NewGitHubCollector("uncached"),
+	})
+	req, err := http.NewRequest("GET", target, nil)
+	if err != nil {
+		t.Fatalf("%+v\n", err)
+	}
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	testRecorder := httptest.NewRecorder()
+	testHandler.ServeHTTP(testRecorder, req)
+	return testRecorder
+}
+
+func TestAPIHandlerFilter(t *testing.T) {
+	setAPICache()
+	defer exporter.Kv.Flush()
+
+	cases := []struct {
+		target  string
+		status  int
+		numbers []int
+	}{
+		{"/api/v1/orgs/ko-da-k/repos/hoge/pulls", http.StatusOK, []int{3, 2, 1}},
+		{"/api/v1/orgs/ko-da-k/repos/hoge/pulls?state=merged", http.StatusOK, []int{1}},
+		{"/api/v1/orgs/ko-da-k/repos/hoge/pulls?state=closed", http.StatusOK, []int{1}},
+		{"/api/v1/orgs/ko-da-k/repos/hoge/pulls?assignee=alice&label=bug", http.StatusOK, []int{2}},
+		{"/api/v1/orgs/ko-da-k/repos/hoge/pulls?since=2020-01-11", http.StatusOK, []int{}},
+		{"/api/v1/orgs/ko-da-k/repos/hoge/pulls?until=2020-01-11T00:00:00Z", http.StatusOK, []int{3, 2, 1}},
+		{"/api/v1/orgs/ko-da-k/repos/hoge/pulls?per_page=2&page=2", http.StatusOK, []int{1}},
+		{"/api/v1/orgs/ko-da-k/repos/hoge/issues?label=bug", http.StatusOK, []int{4}},
+		{"/api/v1/orgs/ko-da-k/repos/hoge/pulls?since=yesterday", http.StatusBadRequest, nil},
+		{"/api/v1/orgs/ko-da-k/repos/hoge/pulls?per_page=101", http.StatusBadRequest, nil},
+		{"/api/v1/orgs/ko-da-k/repos/fuga/pulls", http.StatusNotFound, nil},
+		{"/api/v1/orgs/unknown/repos", http.StatusNotFound, nil},
+	}
+	for _, c := range cases {
+		testRecorder := serveAPI(t, c.target, nil)
+		if status := testRecorder.Code; status != c.status {
+			t.Errorf("%s: handler returned wrong status code: got %v want %v",
+				c.target, status, c.status)
+			continue
+		}
+		if c.status != http.StatusOK {
+			continue
+		}
+		var items []struct {
+			Number int `json:"number"`
+		}
+		if err := json.Unmarshal(testRecorder.Body.Bytes(), &items); err != nil {
+			t.Fatalf("%s: %+v\n", c.target, err)
+		}
+		numbers := []int{}
+		for _, item := range items {
+			numbers = append(numbers, item.Number)
+		}
+		if len(numbers) != len(c.numbers) || (len(numbers) > 0 && numbers[0] != c.numbers[0]) {
+			t.Errorf("%s: got %v want %v", c.target, numbers, c.numbers)
+		}
+	}
+}
+
+func TestAPIHandlerPagination(t *testing.T) {
+	setAPICache()
+	defer exporter.Kv.Flush()
+
+	testRecorder := serveAPI(t, "/api/v1/orgs/ko-da-k/repos/hoge/pulls?per_page=1&page=2", nil)
+	if total := testRecorder.Header().Get("X-Total-Count"); total != "3" {
+		t.Errorf("unexpected X-Total-Count: got %v want %v", total, 3)
+	}
+	link := testRecorder.Header().Get("Link")
+	if !strings.Contains(link, `page=3&per_page=1>; rel="next"`) || !strings.Contains(link, `page=1&per_page=1>; rel="prev"`) {
+		t.Errorf("unexpected Link: got %v", link)
+	}
+}
+
+func TestAPIHandlerPaginationOverflow(t *testing.T) {
+	setAPICache()
+	defer exporter.Kv.Flush()
+
+	// (page-1)*per_page overflows to a negative index
+	testRecorder := serveAPI(t, "/api/v1/orgs/ko-da-k/repos/hoge/pulls?per_page=2&page=4611686018427387905", nil)
+	if status := testRecorder.Code; status != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+	var records []map[string]interface{}
+	if err := json.Unmarshal(testRecorder.Body.Bytes(), &records); err != nil {
+		t.Fatalf("%+v\n", err)
+	}
+	if len(records) != 0 {
+		t.Errorf("page after the last should be empty: got %v", records)
+	}
+	if link := testRecorder.Header().Get("Link"); strings.Contains(link, `rel="next"`) {
+		t.Errorf("unexpected next link: got %v", link)
+	}
+}
+
+func TestAPIHandlerCSV(t *testing.T) {
+	setAPICache()
+	defer exporter.Kv.Flush()
+
+	for _, testRecorder := range []*httptest.ResponseRecorder{
+		serveAPI(t, "/api/v1/orgs/ko-da-k/repos/hoge/pulls?format=csv", nil),
+		serveAPI(t, "/api/v1/orgs/ko-da-k/repos/hoge/pulls", map[string]string{"Accept": "text/csv"}),
+	} {
+		if contentType := testRecorder.Header().Get("Content-Type"); !strings.HasPrefix(contentType, "text/csv") {
+			t.Errorf("handler returned wrong content type: got %v", contentType)
+		}
+		records, err := csv.NewReader(testRecorder.Body).ReadAll()
+		if err != nil {
+			t.Fatalf("%+v\n", err)
+		}
+		if len(records) != 4 || records[0][0] != "number" {
+			t.Fatalf("unexpected records: %v", records)
+		}
+		if records[1][1] != `a, "quoted" title` || records[3][2] != "merged" {
+			t.Errorf("unexpected records: %v", records)
+		}
+	}
+}
+
+func TestAPIHandlerOrgs(t *testing.T) {
+	setAPICache()
+	defer exporter.Kv.Flush()
+
+	testRecorder := serveAPI(t, "/api/v1/orgs", nil)
+	var orgs []struct {
+		Login  string `json:"login"`
+		Cached bool   `json:"cached"`
+	}
+	if err := json.Unmarshal(testRecorder.Body.Bytes(), &orgs); err != nil {
+		t.Fatalf("%+v\n", err)
+	}
+	if len(orgs) != 2 || !orgs[0].Cached || orgs[1].Cached {
+		t.Errorf("unexpected orgs: got %v", orgs)
+	}
+}
@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"net/http"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// dummyHash is compared for unknown users not to leak which users exist by response time
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("dummy"), bcrypt.DefaultCost)
+
+// Authenticator requires basic auth of the users in the web config file, or the bearer token
+type Authenticator struct {
+	webConfig *WebConfigFile
+	token     []byte
+
+	// verified caches successful bcrypt comparisons, which are slow by design, for every scrape
+	mu       sync.Mutex
+	verified map[[sha256.Size]byte]bool
+}
+
+// NewAuthenticator returns nil if neither the web config file nor the token is given.
+// Whether basic auth is required is decided on each request,
+// because users can be added to or removed from the web config file by reload.
+// webConfig can be nil.
+func NewAuthenticator(webConfig *WebConfigFile, token string) *Authenticator {
+	if webConfig == nil && token == "" {
+		return nil
+	}
+	return &Authenticator{
+		webConfig: webConfig,
+		token:     []byte(token),
+		verified:  map[[sha256.Size]byte]bool{},
+	}
+}
+
+// Wrap returns a handler which serves only authenticated requests
+func (a *Authenticator) Wrap(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		users := a.users()
+		// the web config file has no users and no token is given
+		if len(users) == 0 && len(a.token) == 0 {
+			h.ServeHTTP(w, r)
+			return
+		}
+		if !a.authenticated(r, users) {
+			if len(users) > 0 {
+				w.Header().Set("WWW-Authenticate", `Basic realm="github-developer-exporter"`)
+			} else {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="github-developer-exporter"`)
+			}
+			errStatus := http.StatusUnauthorized
+			w.WriteHeader(errStatus)
+			w.Write([]byte(http.StatusText(errStatus)))
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+func (a *Authenticator) users() map[string]string {
+	if a.webConfig == nil {
+		return nil
+	}
+	return a.webConfig.Config().Users
+}
+
+func (a *Authenticator) authenticated(r *http.Request, users map[string]string) bool {
+	if len(a.token) > 0 && validBearerToken(r.Header.Get("Authorization"), a.token) {
+		return true
+	}
+	username, password, ok := r.BasicAuth()
+	if !ok {
+		return false
+	}
+	hash, ok := users[username]
+	if !ok {
+		bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+		return false
+	}
+
+	// the key includes the hash so that changed passwords in the reloaded file are verified again
+	key := sha256.Sum256([]byte(username + "\x00" + password + "\x00" + hash))
+	a.mu.Lock()
+	verified := a.verified[key]
+	a.mu.Unlock()
+	if verified {
+		return true
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)); err != nil {
+		if err != bcrypt.ErrMismatchedHashAndPassword {
+			log.Warnf("invalid password hash of user %s: %v", username, err)
+		}
+		return false
+	}
+	a.mu.Lock()
+	a.verified[key] = true
+	a.mu.Unlock()
+	return true
+}
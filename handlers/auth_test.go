@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestAuthenticator(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("%+v\n", err)
+	}
+	f, err := NewWebConfigFile(writeWebConfig(t, t.TempDir(), fmt.Sprintf("basic_auth_users:\n  alice: %s\n", hash)))
+	if err != nil {
+		t.Fatalf("%+v\n", err)
+	}
+
+	routes := NewRoutes()
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("OK"))
+	})
+	routes.LivenessHandler = ok
+	routes.ReadinessHandler = ok
+	routes.MetricsHandler = ok
+	routes.APIHandler = ok
+	routes.NotFoundHandler = NewNotFoundHandler()
+	routes.Authenticator = NewAuthenticator(f, "token")
+	handler := routes.Handler()
+
+	cases := []struct {
+		target string
+		auth   func(r *http.Request)
+		status int
+	}{
+		{"/health", func(r *http.Request) {}, http.StatusOK},
+		{"/metrics", func(r *http.Request) {}, http.StatusUnauthorized},
+		{"/metrics", func(r *http.Request) { r.SetBasicAuth("alice", "secret") }, http.StatusOK},
+		{"/metrics", func(r *http.Request) { r.SetBasicAuth("alice", "secret") }, http.StatusOK},
+		{"/metrics", func(r *http.Request) { r.SetBasicAuth("alice", "wrong") }, http.StatusUnauthorized},
+		{"/metrics", func(r *http.Request) { r.SetBasicAuth("bob", "secret") }, http.StatusUnauthorized},
+		{"/metrics", func(r *http.Request) { r.Header.Set("Authorization", "Bearer token") }, http.StatusOK},
+		{"/api/v1/orgs", func(r *http.Request) { r.Header.Set("Authorization", "Bearer wrong") }, http.StatusUnauthorized},
+		{"/api/v1/orgs", func(r *http.Request) { r.Header.Set("Authorization", "Bearer token") }, http.StatusOK},
+	}
+	for _, c := range cases {
+		req, err := http.NewRequest("GET", c.target, nil)
+		if err != nil {
+			t.Fatalf("%+v\n", err)
+		}
+		c.auth(req)
+		testRecorder := httptest.NewRecorder()
+		handler.ServeHTTP(testRecorder, req)
+		if status := testRecorder.Code; status != c.status {
+			t.Errorf("%s %v: handler returned wrong status code: got %v want %v",
+				c.target, req.Header, status, c.status)
+		}
+		if c.status == http.StatusUnauthorized && testRecorder.Header().Get("WWW-Authenticate") == "" {
+			t.Errorf("%s: WWW-Authenticate is not set", c.target)
+		}
+	}
+}
+
+func TestAuthenticatorReloadUsers(t *testing.T) {
+	dir := t.TempDir()
+	path := writeWebConfig(t, dir, "")
+	f, err := NewWebConfigFile(path)
+	if err != nil {
+		t.Fatalf("%+v\n", err)
+	}
+	f.checkInterval = 0
+	a := NewAuthenticator(f, "")
+	if a == nil {
+		t.Fatalf("authenticator should be given for the web config file")
+	}
+	handler := a.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("OK"))
+	}))
+	serve := func() int {
+		testRecorder := httptest.NewRecorder()
+		handler.ServeHTTP(testRecorder, httptest.NewRequest("GET", "/metrics", nil))
+		return testRecorder.Code
+	}
+	if status := serve(); status != http.StatusOK {
+		t.Errorf("requests should be served without users: got %v want %v", status, http.StatusOK)
+	}
+
+	// users added by reload are required
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("%+v\n", err)
+	}
+	writeWebConfig(t, dir, fmt.Sprintf("basic_auth_users:\n  alice: %s\n", hash))
+	future := time.Now().Add(time.Minute)
+	os.Chtimes(path, future, future)
+	if status := serve(); status != http.StatusUnauthorized {
+		t.Errorf("users added by reload should be required: got %v want %v", status, http.StatusUnauthorized)
+	}
+}
+
+func TestNewAuthenticatorDisabled(t *testing.T) {
+	if a := NewAuthenticator(nil, ""); a != nil {
+		t.Errorf("authenticator should be disabled: got %v", a)
+	}
+}
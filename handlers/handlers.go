@@ -14,6 +14,17 @@ type Routes struct {
 	ReadinessHandler http.Handler
 	MetricsHandler   http.Handler
 	NotFoundHandler  http.Handler
+	// WebhookHandler is registered only if it is set
+	WebhookHandler http.Handler
+	// APIHandler serves /api/v1/ if it is set
+	APIHandler http.Handler
+	// StatusHandler is registered only if it is set
+	StatusHandler http.Handler
+	// AdminHandler serves /admin/ if it is set
+	AdminHandler http.Handler
+	// Authenticator protects /metrics, /status and /api/v1/ if it is set.
+	// Probes, /webhook and /admin/ have their own authentication.
+	Authenticator *Authenticator
 }
 
 func NewRoutes() *Routes {
@@ -21,10 +32,29 @@ func NewRoutes() *Routes {
 }
 
 func (routes *Routes) Handler() http.Handler {
+	protect := func(h http.Handler) http.Handler {
+		if routes.Authenticator == nil {
+			return h
+		}
+		return routes.Authenticator.Wrap(h)
+	}
+
 	r := mux.NewRouter().StrictSlash(true)
 	r.Handle("/readiness", routes.ReadinessHandler)
 	r.Handle("/health", routes.LivenessHandler)
-	r.Handle("/metrics", routes.MetricsHandler)
+	r.Handle("/metrics", protect(routes.MetricsHandler))
+	if routes.WebhookHandler != nil {
+		r.Handle("/webhook", routes.WebhookHandler)
+	}
+	if routes.StatusHandler != nil {
+		r.Handle("/status", protect(routes.StatusHandler))
+	}
+	if routes.AdminHandler != nil {
+		r.PathPrefix("/admin/").Handler(routes.AdminHandler)
+	}
+	if routes.APIHandler != nil {
+		r.PathPrefix("/api/v1/").Handler(protect(routes.APIHandler))
+	}
 	r.NotFoundHandler = routes.NotFoundHandler
 
 	return ApplyMiddleware(r)
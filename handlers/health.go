@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/ko-da-k/github-developer-exporter/exporter"
+)
+
+// writeChecks writes "OK" or 503, or all checks as JSON if `?verbose` is given.
+// Optional checks do not make it 503.
+func writeChecks(w http.ResponseWriter, r *http.Request, checks []exporter.Check) {
+	if r.Method != http.MethodGet {
+		errStatus := http.StatusMethodNotAllowed
+		w.WriteHeader(errStatus)
+		w.Write([]byte(http.StatusText(errStatus)))
+		return
+	}
+
+	ok := true
+	for _, c := range checks {
+		ok = ok && (c.OK || c.Optional)
+	}
+	status := http.StatusOK
+	if !ok {
+		status = http.StatusServiceUnavailable
+	}
+
+	if _, verbose := r.URL.Query()["verbose"]; verbose {
+		result := "ok"
+		if !ok {
+			result = "fail"
+		}
+		writeJSON(w, status, map[string]interface{}{
+			"status": result,
+			"checks": checks,
+		})
+		return
+	}
+	w.WriteHeader(status)
+	if ok {
+		w.Write([]byte("OK"))
+		return
+	}
+	w.Write([]byte(http.StatusText(status)))
+}
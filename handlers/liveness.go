@@ -2,22 +2,20 @@ package handlers
 
 import (
 	"net/http"
+
+	"github.com/ko-da-k/github-developer-exporter/exporter"
 )
 
-type LivenessHandler struct{}
+// LivenessHandler fails if the dispatcher has stopped or no job has progressed
+type LivenessHandler struct {
+	scheduler    *exporter.Scheduler
+	maxIntervals int
+}
 
-func NewLivenessHandler() http.Handler {
-	return &LivenessHandler{}
+func NewLivenessHandler(scheduler *exporter.Scheduler, maxIntervals int) http.Handler {
+	return &LivenessHandler{scheduler, maxIntervals}
 }
 
 func (h *LivenessHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	if r.Method == http.MethodGet {
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("OK"))
-		return
-	}
-	errStatus := http.StatusMethodNotAllowed
-	w.WriteHeader(errStatus)
-	w.Write([]byte(http.StatusText(errStatus)))
-	return
+	writeChecks(w, r, h.scheduler.LivenessChecks(h.maxIntervals))
 }
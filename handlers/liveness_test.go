@@ -1,20 +1,39 @@
 package handlers
 
 import (
+	"context"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
+
+	"github.com/ko-da-k/github-developer-exporter/exporter"
 )
 
 func TestLivenessHandler(t *testing.T) {
-	testHandler := NewLivenessHandler()
-	testRecorder := httptest.NewRecorder()
+	d := exporter.NewDispatcher(exporter.NewWorker())
+	scheduler := exporter.NewScheduler(d, nil, time.Minute)
+	testHandler := NewLivenessHandler(scheduler, 3)
 
 	req, err := http.NewRequest("GET", "/hoge", nil)
 	if err != nil {
 		t.Fatalf("%+v\n", err)
 	}
 
+	// the dispatcher is not started
+	testRecorder := httptest.NewRecorder()
+	testHandler.ServeHTTP(testRecorder, req)
+	if status := testRecorder.Code; status != http.StatusServiceUnavailable {
+		t.Errorf("handler returned wrong status code: got %v want %v",
+			status, http.StatusServiceUnavailable)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	d.Start(ctx)
+	scheduler.Start(ctx)
+	testRecorder = httptest.NewRecorder()
 	testHandler.ServeHTTP(testRecorder, req)
 
 	if status := testRecorder.Code; status != http.StatusOK {
@@ -29,3 +48,26 @@ func TestLivenessHandler(t *testing.T) {
 			actual, expected)
 	}
 }
+
+func TestLivenessHandlerVerbose(t *testing.T) {
+	scheduler := exporter.NewScheduler(exporter.NewDispatcher(exporter.NewWorker()), nil, time.Minute)
+	testHandler := NewLivenessHandler(scheduler, 3)
+	testRecorder := httptest.NewRecorder()
+
+	req, err := http.NewRequest("GET", "/health?verbose", nil)
+	if err != nil {
+		t.Fatalf("%+v\n", err)
+	}
+	testHandler.ServeHTTP(testRecorder, req)
+
+	body := struct {
+		Status string           `json:"status"`
+		Checks []exporter.Check `json:"checks"`
+	}{}
+	if err := json.Unmarshal(testRecorder.Body.Bytes(), &body); err != nil {
+		t.Fatalf("%+v\n", err)
+	}
+	if body.Status != "fail" || len(body.Checks) != 2 || body.Checks[0].Name != "dispatcher" || body.Checks[0].OK {
+		t.Errorf("handler returned unexpected body\ngot %+v", body)
+	}
+}
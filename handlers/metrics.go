@@ -3,13 +3,55 @@ package handlers
 import (
 	"net/http"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	log "github.com/sirupsen/logrus"
 
 	"github.com/ko-da-k/github-developer-exporter/exporter"
 )
 
+type MetricsHandler struct {
+	gs      []*exporter.GitHubCollector
+	reg     *prometheus.Registry
+	handler http.Handler
+}
+
+// handlerOpts serves metrics gathered successfully even if some collectors fail
+var handlerOpts = promhttp.HandlerOpts{
+	ErrorLog:          log.StandardLogger(),
+	ErrorHandling:     promhttp.ContinueOnError,
+	EnableOpenMetrics: true,
+}
+
 func NewMetricsHandler(gs []*exporter.GitHubCollector) http.Handler {
-	exporter.RecordMetrics(gs)
+	reg := exporter.NewRegistry(gs)
+
+	return &MetricsHandler{gs, reg, promhttp.InstrumentMetricHandler(
+		reg, promhttp.HandlerFor(reg, handlerOpts),
+	)}
+}
+
+// ServeHTTP serves all metrics unless the query has org, repo or family,
+// e.g. /metrics?org=platform&repo=api-.*&family=pull_request
+func (h *MetricsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("org") == "" && q.Get("repo") == "" && q.Get("family") == "" {
+		h.handler.ServeHTTP(w, r)
+		return
+	}
+
+	filter, err := exporter.NewMetricFilter(q["org"], q.Get("repo"), q["family"])
+	var g prometheus.Gatherer
+	if err == nil {
+		g, err = exporter.NewFilteredGatherer(h.reg, h.gs, filter)
+	}
+	if err != nil {
+		errStatus := http.StatusBadRequest
+		w.WriteHeader(errStatus)
+		w.Write([]byte(err.Error()))
+		return
+	}
 
-	return promhttp.Handler()
+	// the dev collector only streams pre-rendered snapshots, so gathering all of them is cheap
+	promhttp.HandlerFor(g, handlerOpts).ServeHTTP(w, r)
 }
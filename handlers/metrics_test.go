@@ -3,29 +3,100 @@ package handlers
 import (
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
-)
 
-func TestMetricsHandler(t *testing.T) {
-	testHandler := NewMetricsHandler()
-	testRecorder := httptest.NewRecorder()
+	"github.com/ko-da-k/github-developer-exporter/exporter"
+)
 
-	req, err := http.NewRequest("GET", "/metrics", nil)
+func newMetricsRequest(t *testing.T, target string) *http.Request {
+	req, err := http.NewRequest("GET", target, nil)
 	if err != nil {
 		t.Fatalf("%+v\n", err)
 	}
+	return req
+}
 
-	testHandler.ServeHTTP(testRecorder, req)
+func TestMetricsHandler(t *testing.T) {
+	gs := []*exporter.GitHubCollector{exporter.NewGitHubCollector("ko-da-k")}
+	// the exporter owns its registry, so handlers can be built repeatedly
+	NewMetricsHandler(gs)
+	testHandler := NewMetricsHandler(gs)
+	testRecorder := httptest.NewRecorder()
+
+	testHandler.ServeHTTP(testRecorder, newMetricsRequest(t, "/metrics"))
 
 	if status := testRecorder.Code; status != http.StatusOK {
 		t.Errorf("handler returned wrong status code: got %v want %v",
 			status, http.StatusOK)
 	}
 
-	expected := "OK"
+	expected := `org_up{org="ko-da-k"} 0`
 	actual := testRecorder.Body.String()
-	if testRecorder.Body.String() != expected {
+	if !strings.Contains(actual, expected) {
 		t.Errorf("handler returned unexpected body\ngot %v\nwant %v",
 			actual, expected)
 	}
 }
+
+func TestMetricsHandlerOpenMetrics(t *testing.T) {
+	testHandler := NewMetricsHandler([]*exporter.GitHubCollector{exporter.NewGitHubCollector("ko-da-k")})
+	testRecorder := httptest.NewRecorder()
+
+	req := newMetricsRequest(t, "/metrics")
+	req.Header.Set("Accept", "application/openmetrics-text; version=0.0.1")
+	testHandler.ServeHTTP(testRecorder, req)
+
+	if contentType := testRecorder.Header().Get("Content-Type"); !strings.HasPrefix(contentType, "application/openmetrics-text") {
+		t.Errorf("handler returned wrong content type: got %v", contentType)
+	}
+	if actual := testRecorder.Body.String(); !strings.HasSuffix(actual, "# EOF\n") {
+		t.Errorf("handler returned unexpected body\ngot %v", actual)
+	}
+}
+
+func TestMetricsHandlerFilter(t *testing.T) {
+	testHandler := NewMetricsHandler([]*exporter.GitHubCollector{
+		exporter.NewGitHubCollector("ko-da-k"),
+		exporter.NewGitHubCollector("other"),
+	})
+
+	cases := []struct {
+		target string
+		status int
+	}{
+		{"/metrics?org=ko-da-k&family=pull_request", http.StatusOK},
+		{"/metrics?org=unknown", http.StatusBadRequest},
+		{"/metrics?repo=(", http.StatusBadRequest},
+	}
+	for _, c := range cases {
+		testRecorder := httptest.NewRecorder()
+		testHandler.ServeHTTP(testRecorder, newMetricsRequest(t, c.target))
+
+		if status := testRecorder.Code; status != c.status {
+			t.Errorf("%s: handler returned wrong status code: got %v want %v",
+				c.target, status, c.status)
+		}
+		if c.status != http.StatusOK {
+			continue
+		}
+		actual := testRecorder.Body.String()
+		if !strings.Contains(actual, `org_up{org="ko-da-k"}`) || strings.Contains(actual, `org_up{org="other"}`) ||
+			strings.Contains(actual, "github_exporter_") {
+			t.Errorf("%s: handler returned unexpected body\ngot %v", c.target, actual)
+		}
+	}
+}
+
+func TestMetricsHandlerFilterExporterMetrics(t *testing.T) {
+	testHandler := NewMetricsHandler([]*exporter.GitHubCollector{exporter.NewGitHubCollector("ko-da-k")})
+	testRecorder := httptest.NewRecorder()
+
+	// metrics registered besides the collectors of orgs can also be selected
+	testHandler.ServeHTTP(testRecorder, newMetricsRequest(t, "/metrics?family=promhttp_"))
+
+	actual := testRecorder.Body.String()
+	if !strings.Contains(actual, "promhttp_metric_handler_requests_total") || strings.Contains(actual, "pull_request_info") {
+		t.Errorf("handler returned unexpected body\ngot %v", actual)
+	}
+}
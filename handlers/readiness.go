@@ -2,22 +2,19 @@ package handlers
 
 import (
 	"net/http"
+
+	"github.com/ko-da-k/github-developer-exporter/exporter"
 )
 
-type ReadinessHandler struct{}
+// ReadinessHandler is not ready until every org has completed at least one successful job
+type ReadinessHandler struct {
+	scheduler *exporter.Scheduler
+}
 
-func NewReadinessHandler() http.Handler {
-	return &ReadinessHandler{}
+func NewReadinessHandler(scheduler *exporter.Scheduler) http.Handler {
+	return &ReadinessHandler{scheduler}
 }
 
 func (h *ReadinessHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	if r.Method == http.MethodGet {
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("OK"))
-		return
-	}
-	errStatus := http.StatusMethodNotAllowed
-	w.WriteHeader(errStatus)
-	w.Write([]byte(http.StatusText(errStatus)))
-	return
+	writeChecks(w, r, h.scheduler.ReadinessChecks())
 }
@@ -1,20 +1,54 @@
 package handlers
 
 import (
+	"context"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"testing"
+	"time"
+
+	"github.com/google/go-github/v28/github"
+
+	"github.com/ko-da-k/github-developer-exporter/exporter"
 )
 
 func TestReadinessHandler(t *testing.T) {
-	testHandler := NewReadinessHandler()
-	testRecorder := httptest.NewRecorder()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/orgs/ko-da-k", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"login":"ko-da-k"}`))
+	})
+	mux.HandleFunc("/orgs/ko-da-k/repos", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[]`))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+	defer exporter.Kv.Flush()
+
+	job := exporter.NewJob(client, "ko-da-k")
+	scheduler := exporter.NewScheduler(exporter.NewDispatcher(exporter.NewWorker()), []*exporter.Job{job}, time.Minute)
+	testHandler := NewReadinessHandler(scheduler)
 
 	req, err := http.NewRequest("GET", "/hoge", nil)
 	if err != nil {
 		t.Fatalf("%+v\n", err)
 	}
 
+	// no job has succeeded yet
+	testRecorder := httptest.NewRecorder()
+	testHandler.ServeHTTP(testRecorder, req)
+	if status := testRecorder.Code; status != http.StatusServiceUnavailable {
+		t.Errorf("handler returned wrong status code: got %v want %v",
+			status, http.StatusServiceUnavailable)
+	}
+
+	if err := job.Execute(context.Background()); err != nil {
+		t.Fatalf("%+v\n", err)
+	}
+	testRecorder = httptest.NewRecorder()
 	testHandler.ServeHTTP(testRecorder, req)
 
 	if status := testRecorder.Code; status != http.StatusOK {
@@ -29,3 +63,49 @@ func TestReadinessHandler(t *testing.T) {
 			actual, expected)
 	}
 }
+
+func TestReadinessHandlerVerbose(t *testing.T) {
+	scheduler := exporter.NewScheduler(
+		exporter.NewDispatcher(exporter.NewWorker()),
+		[]*exporter.Job{exporter.NewJob(nil, "ko-da-k")},
+		time.Minute,
+	)
+	testHandler := NewReadinessHandler(scheduler)
+	testRecorder := httptest.NewRecorder()
+
+	req, err := http.NewRequest("GET", "/readiness?verbose", nil)
+	if err != nil {
+		t.Fatalf("%+v\n", err)
+	}
+	testHandler.ServeHTTP(testRecorder, req)
+
+	if status := testRecorder.Code; status != http.StatusServiceUnavailable {
+		t.Errorf("handler returned wrong status code: got %v want %v",
+			status, http.StatusServiceUnavailable)
+	}
+	body := struct {
+		Status string           `json:"status"`
+		Checks []exporter.Check `json:"checks"`
+	}{}
+	if err := json.Unmarshal(testRecorder.Body.Bytes(), &body); err != nil {
+		t.Fatalf("%+v\n", err)
+	}
+	if body.Status != "fail" || len(body.Checks) != 2 || body.Checks[0].Name != "org:ko-da-k" || !body.Checks[1].Optional {
+		t.Errorf("handler returned unexpected body\ngot %+v", body)
+	}
+}
+
+func TestWriteChecksOptional(t *testing.T) {
+	req, err := http.NewRequest("GET", "/readiness", nil)
+	if err != nil {
+		t.Fatalf("%+v\n", err)
+	}
+	testRecorder := httptest.NewRecorder()
+	writeChecks(testRecorder, req, []exporter.Check{
+		{Name: "org:ko-da-k", OK: true},
+		{Name: "repos:ko-da-k", OK: false, Optional: true},
+	})
+	if status := testRecorder.Code; status != http.StatusOK {
+		t.Errorf("failed optional check should not be 503: got %v want %v", status, http.StatusOK)
+	}
+}
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/ko-da-k/github-developer-exporter/exporter"
+)
+
+var statusTemplate = template.Must(template.New("status").Funcs(template.FuncMap{
+	"time": func(t time.Time) string {
+		if t.IsZero() {
+			return "-"
+		}
+		return t.Format(time.RFC3339)
+	},
+	"seconds": func(s float64) string {
+		if s < 0 {
+			return "-"
+		}
+		return (time.Duration(s) * time.Second).Round(time.Second).String()
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>github-developer-exporter status</title>
+<style>
+body { font-family: sans-serif; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; }
+.success { color: green; }
+.failure { color: red; }
+.partial { color: orange; }
+</style>
+</head>
+<body>
+<h1>github-developer-exporter status</h1>
+<p>{{ time .Time }} (<a href="?format=json">JSON</a>)</p>
+<h2>Organizations</h2>
+<table>
+<tr><th>Org</th><th>Running</th><th>Last started</th><th>Last finished</th><th>Duration</th><th>Outcome</th><th>Last error</th><th>Failed repos</th><th>Repos</th><th>Pull requests</th><th>Issues</th><th>Data age</th></tr>
+{{- range .Orgs }}
+<tr>
+<td>{{ .Org }}</td>
+<td>{{ .Running }}</td>
+<td>{{ time .LastStartedAt }}</td>
+<td>{{ time .LastFinishedAt }}</td>
+<td>{{ if .LastOutcome }}{{ seconds .LastDurationSeconds }}{{ else }}-{{ end }}</td>
+<td class="{{ .LastOutcome }}">{{ or .LastOutcome "-" }}</td>
+<td>{{ .LastError }}</td>
+<td>{{ .FailedRepos }}</td>
+<td>{{ .Repos }}</td>
+<td>{{ .PullRequests }}</td>
+<td>{{ .Issues }}</td>
+<td>{{ seconds .DataAgeSeconds }}</td>
+</tr>
+{{- end }}
+</table>
+<h2>Rate limit</h2>
+{{- if lt .RateLimit.Remaining 0 }}
+<p>unknown until the first response</p>
+{{- else }}
+<p>{{ .RateLimit.Remaining }} / {{ .RateLimit.Limit }} remaining, reset at {{ time .RateLimit.Reset }}</p>
+{{- end }}
+<h2>Dispatcher</h2>
+<p>queue: {{ .Dispatcher.QueuedJobs }} / {{ .Dispatcher.QueueCapacity }}, busy workers: {{ .Dispatcher.BusyWorkers }} / {{ .Dispatcher.Workers }}</p>
+</body>
+</html>
+`))
+
+type StatusHandler struct {
+	jobs       []*exporter.Job
+	dispatcher *exporter.Dispatcher
+}
+
+func NewStatusHandler(jobs []*exporter.Job, dispatcher *exporter.Dispatcher) http.Handler {
+	return &StatusHandler{jobs, dispatcher}
+}
+
+// ServeHTTP serves HTML, or JSON if `?format=json` or `Accept: application/json` is given
+func (h *StatusHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	status := exporter.CollectStatus(h.jobs, h.dispatcher)
+
+	format := r.URL.Query().Get("format")
+	if format == "json" || (format == "" && strings.Contains(r.Header.Get("Accept"), "application/json")) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(status); err != nil {
+			log.Warnf("failed to write status: %v", err)
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := statusTemplate.Execute(w, status); err != nil {
+		log.Warnf("failed to write status: %v", err)
+	}
+}
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ko-da-k/github-developer-exporter/exporter"
+)
+
+func TestStatusHandler(t *testing.T) {
+	testHandler := NewStatusHandler(
+		[]*exporter.Job{exporter.NewJob(nil, "ko-da-k")},
+		exporter.NewDispatcher(exporter.NewWorker()),
+	)
+
+	cases := []struct {
+		target      string
+		accept      string
+		contentType string
+	}{
+		{"/status", "", "text/html"},
+		{"/status?format=json", "", "application/json"},
+		{"/status", "application/json", "application/json"},
+	}
+	for _, c := range cases {
+		req, err := http.NewRequest("GET", c.target, nil)
+		if err != nil {
+			t.Fatalf("%+v\n", err)
+		}
+		req.Header.Set("Accept", c.accept)
+		testRecorder := httptest.NewRecorder()
+		testHandler.ServeHTTP(testRecorder, req)
+
+		if status := testRecorder.Code; status != http.StatusOK {
+			t.Errorf("%s: handler returned wrong status code: got %v want %v",
+				c.target, status, http.StatusOK)
+		}
+		if contentType := testRecorder.Header().Get("Content-Type"); !strings.HasPrefix(contentType, c.contentType) {
+			t.Errorf("%s: handler returned wrong content type: got %v want %v", c.target, contentType, c.contentType)
+		}
+		if c.contentType == "text/html" {
+			if !strings.Contains(testRecorder.Body.String(), "<td>ko-da-k</td>") {
+				t.Errorf("handler returned unexpected body\ngot %v", testRecorder.Body.String())
+			}
+			continue
+		}
+		status := &exporter.Status{}
+		if err := json.Unmarshal(testRecorder.Body.Bytes(), status); err != nil {
+			t.Fatalf("%+v\n", err)
+		}
+		if len(status.Orgs) != 1 || status.Orgs[0].Org != "ko-da-k" || status.Orgs[0].DataAgeSeconds != -1 {
+			t.Errorf("unexpected status: %+v", status)
+		}
+	}
+}
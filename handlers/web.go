@@ -0,0 +1,367 @@
+package handlers
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v2"
+)
+
+// WebConfig is the web config file of Prometheus exporter-toolkit.
+// ref: https://github.com/prometheus/exporter-toolkit/blob/master/docs/web-configuration.md
+type WebConfig struct {
+	TLSConfig  TLSConfig  `yaml:"tls_server_config"`
+	HTTPConfig HTTPConfig `yaml:"http_server_config"`
+	// Users are usernames and bcrypt hashes of their passwords
+	Users map[string]string `yaml:"basic_auth_users"`
+}
+
+type TLSConfig struct {
+	CertFile          string   `yaml:"cert_file"`
+	KeyFile           string   `yaml:"key_file"`
+	ClientAuth        string   `yaml:"client_auth_type"`
+	ClientCAFile      string   `yaml:"client_ca_file"`
+	ClientAllowedSans []string `yaml:"client_allowed_sans"`
+	CipherSuites      []string `yaml:"cipher_suites"`
+	CurvePreferences  []string `yaml:"curve_preferences"`
+	MinVersion        string   `yaml:"min_version"`
+	MaxVersion        string   `yaml:"max_version"`
+	// PreferServerCipherSuites is accepted for compatibility but ignored since Go 1.18
+	PreferServerCipherSuites bool `yaml:"prefer_server_cipher_suites"`
+}
+
+type HTTPConfig struct {
+	// HTTP2 is enabled if it is not set
+	HTTP2   *bool             `yaml:"http2"`
+	Headers map[string]string `yaml:"headers"`
+}
+
+// Enabled reports whether TLS is configured
+func (c *TLSConfig) Enabled() bool {
+	return c.CertFile != "" || c.KeyFile != ""
+}
+
+var (
+	clientAuthTypes = map[string]tls.ClientAuthType{
+		"":                           tls.NoClientCert,
+		"NoClientCert":               tls.NoClientCert,
+		"RequestClientCert":          tls.RequestClientCert,
+		"RequireAnyClientCert":       tls.RequireAnyClientCert,
+		"VerifyClientCertIfGiven":    tls.VerifyClientCertIfGiven,
+		"RequireAndVerifyClientCert": tls.RequireAndVerifyClientCert,
+	}
+	tlsVersions = map[string]uint16{
+		"TLS10": tls.VersionTLS10,
+		"TLS11": tls.VersionTLS11,
+		"TLS12": tls.VersionTLS12,
+		"TLS13": tls.VersionTLS13,
+	}
+	curves = map[string]tls.CurveID{
+		"CurveP256": tls.CurveP256,
+		"CurveP384": tls.CurveP384,
+		"CurveP521": tls.CurveP521,
+		"X25519":    tls.X25519,
+	}
+)
+
+// loadWebConfig reads the web config file and the TLS config built from it.
+// Relative paths in it are resolved from the directory of the file.
+func loadWebConfig(path string) (*WebConfig, *tls.Config, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	c := &WebConfig{}
+	if err := yaml.UnmarshalStrict(content, c); err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", path, err)
+	}
+	dir := filepath.Dir(path)
+	for _, p := range []*string{&c.TLSConfig.CertFile, &c.TLSConfig.KeyFile, &c.TLSConfig.ClientCAFile} {
+		if *p != "" && !filepath.IsAbs(*p) {
+			*p = filepath.Join(dir, *p)
+		}
+	}
+	tlsConfig, err := c.TLSConfig.build()
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return c, tlsConfig, nil
+}
+
+// build returns the TLS config with the certificates read from the files, or nil if TLS is not configured
+func (c *TLSConfig) build() (*tls.Config, error) {
+	if !c.Enabled() {
+		if c.ClientAuth != "" || c.ClientCAFile != "" {
+			return nil, errors.New("client_auth_type and client_ca_file require cert_file and key_file")
+		}
+		return nil, nil
+	}
+	if c.CertFile == "" || c.KeyFile == "" {
+		return nil, errors.New("both cert_file and key_file are required")
+	}
+	cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load certificate: %w", err)
+	}
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	clientAuth, ok := clientAuthTypes[c.ClientAuth]
+	if !ok {
+		return nil, fmt.Errorf("unknown client_auth_type %q", c.ClientAuth)
+	}
+	config.ClientAuth = clientAuth
+	if c.ClientCAFile != "" {
+		pem, err := ioutil.ReadFile(c.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read client_ca_file: %w", err)
+		}
+		config.ClientCAs = x509.NewCertPool()
+		if !config.ClientCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate in client_ca_file %s", c.ClientCAFile)
+		}
+	} else if clientAuth == tls.VerifyClientCertIfGiven || clientAuth == tls.RequireAndVerifyClientCert {
+		return nil, fmt.Errorf("client_ca_file is required for %s", c.ClientAuth)
+	}
+	if len(c.ClientAllowedSans) > 0 {
+		if clientAuth != tls.RequireAndVerifyClientCert {
+			return nil, errors.New("client_allowed_sans requires RequireAndVerifyClientCert")
+		}
+		config.VerifyConnection = verifyClientSans(c.ClientAllowedSans)
+	}
+
+	if c.MinVersion != "" {
+		if config.MinVersion, ok = tlsVersions[c.MinVersion]; !ok {
+			return nil, fmt.Errorf("unknown min_version %q", c.MinVersion)
+		}
+	}
+	if c.MaxVersion != "" {
+		if config.MaxVersion, ok = tlsVersions[c.MaxVersion]; !ok {
+			return nil, fmt.Errorf("unknown max_version %q", c.MaxVersion)
+		}
+	}
+	for _, name := range c.CipherSuites {
+		id, err := cipherSuite(name)
+		if err != nil {
+			return nil, err
+		}
+		config.CipherSuites = append(config.CipherSuites, id)
+	}
+	for _, name := range c.CurvePreferences {
+		curve, ok := curves[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown curve %q", name)
+		}
+		config.CurvePreferences = append(config.CurvePreferences, curve)
+	}
+	return config, nil
+}
+
+func cipherSuite(name string) (uint16, error) {
+	for _, suites := range [][]*tls.CipherSuite{tls.CipherSuites(), tls.InsecureCipherSuites()} {
+		for _, suite := range suites {
+			if suite.Name == name {
+				return suite.ID, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("unknown cipher suite %q", name)
+}
+
+// verifyClientSans accepts a client certificate which has one of the allowed DNS names, emails, IPs or URIs
+func verifyClientSans(allowed []string) func(tls.ConnectionState) error {
+	return func(state tls.ConnectionState) error {
+		if len(state.PeerCertificates) == 0 {
+			return errors.New("no client certificate")
+		}
+		cert := state.PeerCertificates[0]
+		var sans []string
+		sans = append(sans, cert.DNSNames...)
+		sans = append(sans, cert.EmailAddresses...)
+		for _, ip := range cert.IPAddresses {
+			sans = append(sans, ip.String())
+		}
+		for _, uri := range cert.URIs {
+			sans = append(sans, uri.String())
+		}
+		for _, san := range sans {
+			for _, a := range allowed {
+				if san == a {
+					return nil
+				}
+			}
+		}
+		return fmt.Errorf("client certificate SAN %v is not allowed", sans)
+	}
+}
+
+// webConfigCheckInterval is how often the files are checked for modification,
+// not to stat them several times on every request and handshake
+const webConfigCheckInterval = time.Second
+
+// WebConfigFile reloads the web config file, and the certificates in it,
+// when any of them is modified, so rotated certificates and passwords are used without restart.
+type WebConfigFile struct {
+	path          string
+	checkInterval time.Duration
+
+	mu        sync.RWMutex
+	config    *WebConfig
+	tls       *tls.Config
+	modTimes  map[string]time.Time
+	checkedAt time.Time
+}
+
+func NewWebConfigFile(path string) (*WebConfigFile, error) {
+	f := &WebConfigFile{path: path, checkInterval: webConfigCheckInterval}
+	if err := f.load(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *WebConfigFile) load() error {
+	config, tlsConfig, err := loadWebConfig(f.path)
+	if err != nil {
+		return err
+	}
+	modTimes := map[string]time.Time{}
+	for _, path := range []string{f.path, config.TLSConfig.CertFile, config.TLSConfig.KeyFile, config.TLSConfig.ClientCAFile} {
+		modTimes[path] = modTime(path)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.config = config
+	f.tls = tlsConfig
+	f.modTimes = modTimes
+	return nil
+}
+
+func modTime(path string) time.Time {
+	if path == "" {
+		return time.Time{}
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
+// current reloads the files if any of them is modified.
+// They are checked at most once per checkInterval.
+// The previous config is kept if the new one is invalid, e.g. while a certificate and its key are replaced.
+func (f *WebConfigFile) current() (*WebConfig, *tls.Config) {
+	now := time.Now()
+	f.mu.Lock()
+	config, tlsConfig := f.config, f.tls
+	if now.Sub(f.checkedAt) < f.checkInterval {
+		f.mu.Unlock()
+		return config, tlsConfig
+	}
+	f.checkedAt = now
+	modified := false
+	for path, t := range f.modTimes {
+		if !modTime(path).Equal(t) {
+			modified = true
+			break
+		}
+	}
+	f.mu.Unlock()
+	if !modified {
+		return config, tlsConfig
+	}
+
+	if err := f.load(); err != nil {
+		log.Warnf("failed to reload web config, keep the previous one: %v", err)
+		// retry on the next modification, not on every request
+		f.mu.Lock()
+		for path := range f.modTimes {
+			f.modTimes[path] = modTime(path)
+		}
+		f.mu.Unlock()
+		return config, tlsConfig
+	}
+	log.Infof("web config reloaded: %s", f.path)
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.config, f.tls
+}
+
+// Config returns the latest web config
+func (f *WebConfigFile) Config() *WebConfig {
+	config, _ := f.current()
+	return config
+}
+
+// ServerTLSConfig returns the TLS config of the server which reloads the certificates on each handshake,
+// or nil if TLS is not configured.
+func (f *WebConfigFile) ServerTLSConfig() *tls.Config {
+	config, tlsConfig := f.current()
+	if tlsConfig == nil {
+		return nil
+	}
+	nextProtos := []string{"h2", "http/1.1"}
+	if config.HTTPConfig.HTTP2 != nil && !*config.HTTPConfig.HTTP2 {
+		nextProtos = []string{"http/1.1"}
+	}
+	return &tls.Config{
+		// ServeTLS of Go 1.21 requires Certificates or GetCertificate,
+		// though the certificate is given by GetConfigForClient on each handshake
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			_, tlsConfig := f.current()
+			if tlsConfig == nil {
+				return nil, errors.New("TLS is disabled in the web config")
+			}
+			return &tlsConfig.Certificates[0], nil
+		},
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			_, tlsConfig := f.current()
+			if tlsConfig == nil {
+				return nil, errors.New("TLS is disabled in the web config")
+			}
+			c := tlsConfig.Clone()
+			c.NextProtos = nextProtos
+			return c, nil
+		},
+		NextProtos: nextProtos,
+	}
+}
+
+// ConfigureServer sets TLS and HTTP settings of the web config to the server.
+// The server should be started with ListenAndServeTLS("", "") if it returns true, as ListenAndServe does.
+func (f *WebConfigFile) ConfigureServer(server *http.Server) bool {
+	config := f.Config()
+	if config.HTTPConfig.HTTP2 != nil && !*config.HTTPConfig.HTTP2 {
+		server.TLSNextProto = map[string]func(*http.Server, *tls.Conn, http.Handler){}
+	}
+	next := server.Handler
+	server.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for k, v := range f.Config().HTTPConfig.Headers {
+			w.Header().Set(k, v)
+		}
+		next.ServeHTTP(w, r)
+	})
+	server.TLSConfig = f.ServerTLSConfig()
+	return server.TLSConfig != nil
+}
+
+// ListenAndServe serves HTTPS if TLS is configured in the web config file, or HTTP if webConfig is nil
+func ListenAndServe(server *http.Server, webConfig *WebConfigFile) error {
+	if webConfig != nil && webConfig.ConfigureServer(server) {
+		// certificates are given by TLSConfig to reload them
+		return server.ListenAndServeTLS("", "")
+	}
+	return server.ListenAndServe()
+}
@@ -0,0 +1,275 @@
+package handlers
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeCert writes a certificate signed by parent, or self-signed if parent is nil
+func writeCert(t *testing.T, dir, name, commonName string, parent *tls.Certificate) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("%+v\n", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: commonName},
+		DNSNames:              []string{commonName},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  parent == nil,
+	}
+	signer, signerKey := template, interface{}(key)
+	if parent != nil {
+		signer, signerKey = parent.Leaf, parent.PrivateKey
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatalf("%+v\n", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("%+v\n", err)
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	if err := ioutil.WriteFile(filepath.Join(dir, name+".crt"), certPEM, 0600); err != nil {
+		t.Fatalf("%+v\n", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, name+".key"), keyPEM, 0600); err != nil {
+		t.Fatalf("%+v\n", err)
+	}
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatalf("%+v\n", err)
+	}
+	cert.Leaf, _ = x509.ParseCertificate(der)
+	return cert
+}
+
+func writeWebConfig(t *testing.T, dir, content string) string {
+	path := filepath.Join(dir, "web.yml")
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("%+v\n", err)
+	}
+	return path
+}
+
+// startTLSServer serves "OK" with the TLS config of the web config file
+func startTLSServer(t *testing.T, f *WebConfigFile) *httptest.Server {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("OK"))
+	}))
+	server.TLS = f.ServerTLSConfig()
+	server.StartTLS()
+	return server
+}
+
+func TestWebConfigFileReload(t *testing.T) {
+	dir := t.TempDir()
+	first := writeCert(t, dir, "server", "first", nil)
+	path := writeWebConfig(t, dir, `
+tls_server_config:
+  cert_file: server.crt
+  key_file: server.key
+`)
+	f, err := NewWebConfigFile(path)
+	if err != nil {
+		t.Fatalf("%+v\n", err)
+	}
+	f.checkInterval = 0
+	server := startTLSServer(t, f)
+	defer server.Close()
+
+	commonName := func() string {
+		conn, err := tls.Dial("tcp", server.Listener.Addr().String(), &tls.Config{InsecureSkipVerify: true})
+		if err != nil {
+			t.Fatalf("%+v\n", err)
+		}
+		defer conn.Close()
+		return conn.ConnectionState().PeerCertificates[0].Subject.CommonName
+	}
+	if cn := commonName(); cn != first.Leaf.Subject.CommonName {
+		t.Errorf("unexpected certificate: got %v want %v", cn, "first")
+	}
+
+	// rotate the certificate
+	writeCert(t, dir, "server", "second", nil)
+	future := time.Now().Add(time.Minute)
+	for _, name := range []string{"server.crt", "server.key"} {
+		os.Chtimes(filepath.Join(dir, name), future, future)
+	}
+	if cn := commonName(); cn != "second" {
+		t.Errorf("certificate is not reloaded: got %v want %v", cn, "second")
+	}
+
+	// an invalid file keeps the previous certificate
+	ioutil.WriteFile(filepath.Join(dir, "server.key"), []byte("broken"), 0600)
+	future = future.Add(time.Minute)
+	os.Chtimes(filepath.Join(dir, "server.key"), future, future)
+	if cn := commonName(); cn != "second" {
+		t.Errorf("previous certificate is not kept: got %v want %v", cn, "second")
+	}
+}
+
+func TestWebConfigFileCheckInterval(t *testing.T) {
+	dir := t.TempDir()
+	path := writeWebConfig(t, dir, "basic_auth_users:\n  alice: hash\n")
+	f, err := NewWebConfigFile(path)
+	if err != nil {
+		t.Fatalf("%+v\n", err)
+	}
+	f.checkInterval = time.Hour
+	f.Config()
+
+	// the modification is not checked until the interval passes
+	writeWebConfig(t, dir, "basic_auth_users:\n  bob: hash\n")
+	future := time.Now().Add(time.Minute)
+	os.Chtimes(path, future, future)
+	if _, ok := f.Config().Users["alice"]; !ok {
+		t.Errorf("files should not be checked within the interval")
+	}
+	f.checkInterval = 0
+	if _, ok := f.Config().Users["bob"]; !ok {
+		t.Errorf("modified file is not reloaded after the interval")
+	}
+}
+
+func TestListenAndServeTLS(t *testing.T) {
+	dir := t.TempDir()
+	writeCert(t, dir, "server", "localhost", nil)
+	path := writeWebConfig(t, dir, `
+tls_server_config:
+  cert_file: server.crt
+  key_file: server.key
+`)
+	f, err := NewWebConfigFile(path)
+	if err != nil {
+		t.Fatalf("%+v\n", err)
+	}
+	if c := f.ServerTLSConfig(); c.GetCertificate == nil {
+		t.Fatalf("ServeTLS requires GetCertificate or Certificates")
+	}
+
+	// a free port for ListenAndServeTLS which does not take a listener
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("%+v\n", err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+	server := &http.Server{
+		Addr: addr,
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("OK"))
+		}),
+	}
+	errCh := make(chan error, 1)
+	go func() { errCh <- ListenAndServe(server, f) }()
+	defer server.Close()
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		select {
+		case err := <-errCh:
+			t.Fatalf("server stopped: %+v", err)
+		default:
+		}
+		resp, err := client.Get("https://" + addr + "/")
+		if err == nil {
+			resp.Body.Close()
+			if resp.TLS == nil || resp.StatusCode != http.StatusOK {
+				t.Errorf("unexpected response: %v", resp.Status)
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%+v\n", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestWebConfigFileClientAuth(t *testing.T) {
+	dir := t.TempDir()
+	writeCert(t, dir, "server", "localhost", nil)
+	ca := writeCert(t, dir, "ca", "ca", nil)
+	allowed := writeCert(t, dir, "allowed", "allowed", &ca)
+	denied := writeCert(t, dir, "denied", "denied", &ca)
+	path := writeWebConfig(t, dir, `
+tls_server_config:
+  cert_file: server.crt
+  key_file: server.key
+  client_auth_type: RequireAndVerifyClientCert
+  client_ca_file: ca.crt
+  client_allowed_sans: [allowed]
+`)
+	f, err := NewWebConfigFile(path)
+	if err != nil {
+		t.Fatalf("%+v\n", err)
+	}
+	server := startTLSServer(t, f)
+	defer server.Close()
+
+	cases := []struct {
+		name  string
+		certs []tls.Certificate
+		ok    bool
+	}{
+		{"allowed", []tls.Certificate{allowed}, true},
+		{"not allowed SAN", []tls.Certificate{denied}, false},
+		{"no certificate", nil, false},
+	}
+	for _, c := range cases {
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+			InsecureSkipVerify: true,
+			Certificates:       c.certs,
+		}}}
+		resp, err := client.Get(server.URL)
+		if (err == nil) != c.ok {
+			t.Errorf("%s: got error %v", c.name, err)
+		}
+		if err == nil {
+			resp.Body.Close()
+		}
+	}
+}
+
+func TestLoadWebConfigError(t *testing.T) {
+	dir := t.TempDir()
+	writeCert(t, dir, "server", "localhost", nil)
+	cases := []struct {
+		content string
+		err     string
+	}{
+		{"tls_server_config:\n  cert_file: server.crt\n", "both cert_file and key_file"},
+		{"tls_server_config:\n  cert_file: server.crt\n  key_file: server.key\n  client_auth_type: RequireAndVerifyClientCert\n", "client_ca_file is required"},
+		{"tls_server_config:\n  cert_file: server.crt\n  key_file: server.key\n  min_version: SSL3\n", "unknown min_version"},
+		{"unknown_field: true\n", "not found in type"},
+	}
+	for _, c := range cases {
+		if _, err := NewWebConfigFile(writeWebConfig(t, dir, c.content)); err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Errorf("%q: got %v want %v", c.content, err, c.err)
+		}
+	}
+}
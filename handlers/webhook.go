@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/google/go-github/v28/github"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/ko-da-k/github-developer-exporter/exporter"
+)
+
+const (
+	signatureHeader = "X-Hub-Signature-256"
+	// GitHub caps webhook payloads at 25 MB
+	maxPayloadSize = 25 << 20
+)
+
+type WebhookHandler struct {
+	secret []byte
+}
+
+func NewWebhookHandler(secret string) http.Handler {
+	return &WebhookHandler{[]byte(secret)}
+}
+
+func (h *WebhookHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		errStatus := http.StatusMethodNotAllowed
+		w.WriteHeader(errStatus)
+		w.Write([]byte(http.StatusText(errStatus)))
+		return
+	}
+
+	payload, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxPayloadSize))
+	if err != nil {
+		errStatus := http.StatusBadRequest
+		w.WriteHeader(errStatus)
+		w.Write([]byte(http.StatusText(errStatus)))
+		return
+	}
+	if err := validateSignature(r.Header.Get(signatureHeader), payload, h.secret); err != nil {
+		log.Warnf("invalid webhook signature: %v", err)
+		errStatus := http.StatusUnauthorized
+		w.WriteHeader(errStatus)
+		w.Write([]byte(http.StatusText(errStatus)))
+		return
+	}
+
+	event, err := parseWebHook(github.WebHookType(r), payload)
+	if err != nil {
+		log.Warnf("failed to parse webhook %s: %v", github.DeliveryID(r), err)
+		errStatus := http.StatusBadRequest
+		w.WriteHeader(errStatus)
+		w.Write([]byte(http.StatusText(errStatus)))
+		return
+	}
+	if err := exporter.ApplyEvent(github.DeliveryID(r), event); err != nil {
+		// e.g. ping event. GitHub does not need to redeliver it.
+		log.Debugf("ignore webhook %s: %v", github.DeliveryID(r), err)
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("OK"))
+}
+
+// validateSignature validates HMAC-SHA256 signature of the payload.
+// signature is "sha256=<hex digest>".
+func validateSignature(signature string, payload, secret []byte) error {
+	if !strings.HasPrefix(signature, "sha256=") {
+		return errors.New("sha256 signature not found")
+	}
+	actual, err := hex.DecodeString(strings.TrimPrefix(signature, "sha256="))
+	if err != nil {
+		return err
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(payload)
+	if !hmac.Equal(actual, mac.Sum(nil)) {
+		return errors.New("payload signature check failed")
+	}
+	return nil
+}
+
+// parseWebHook parses the payload.
+// workflow_run is parsed by exporter because go-github v28 does not support it.
+func parseWebHook(eventType string, payload []byte) (interface{}, error) {
+	if eventType == "workflow_run" {
+		event := &exporter.WorkflowRunEvent{}
+		if err := json.Unmarshal(payload, event); err != nil {
+			return nil, err
+		}
+		return event, nil
+	}
+	return github.ParseWebHook(eventType, payload)
+}
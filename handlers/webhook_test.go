@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-github/v28/github"
+	"github.com/patrickmn/go-cache"
+
+	"github.com/ko-da-k/github-developer-exporter/exporter"
+)
+
+const testSecret = "secret"
+
+func sign(payload []byte, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func newWebhookRequest(t *testing.T, eventType string, fixture string, secret string) *http.Request {
+	payload, err := ioutil.ReadFile(filepath.Join("testdata", fixture))
+	if err != nil {
+		t.Fatalf("%+v\n", err)
+	}
+	req, err := http.NewRequest("POST", "/webhook", bytes.NewReader(payload))
+	if err != nil {
+		t.Fatalf("%+v\n", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-GitHub-Event", eventType)
+	req.Header.Set("X-GitHub-Delivery", fixture)
+	req.Header.Set("X-Hub-Signature-256", sign(payload, secret))
+	return req
+}
+
+func setWebhookCache() {
+	exporter.Kv.Set("ko-da-k-repos", []*github.Repository{
+		{ID: github.Int64(1), Name: github.String("hoge")},
+	}, cache.DefaultExpiration)
+	exporter.Kv.Set("ko-da-k-hoge-pulls", []*github.PullRequest{
+		{Number: github.Int(1), State: github.String("closed")},
+	}, cache.DefaultExpiration)
+	exporter.Kv.Set("ko-da-k-hoge-issues", []*github.Issue{
+		{Number: github.Int(1), State: github.String("open")},
+	}, cache.DefaultExpiration)
+}
+
+func TestWebhookHandler(t *testing.T) {
+	setWebhookCache()
+	testHandler := NewWebhookHandler(testSecret)
+	g := exporter.NewGitHubCollector("ko-da-k")
+
+	cases := []struct {
+		eventType string
+		fixture   string
+		check     func() error
+	}{
+		{"pull_request", "pull_request.json", func() error {
+			pulls, err := g.GetPullRequestsByRepo("hoge")
+			if err == nil && (len(pulls) != 2 || pulls[0].GetNumber() != 2) {
+				t.Errorf("pull request was not added: %v", pulls)
+			}
+			return err
+		}},
+		{"issues", "issues.json", func() error {
+			issues, err := g.GetIssuesByRepo("hoge")
+			if err == nil && (len(issues) != 1 || issues[0].GetState() != "closed") {
+				t.Errorf("issue was not updated: %v", issues)
+			}
+			return err
+		}},
+		{"workflow_run", "workflow_run.json", func() error {
+			runs, err := g.GetWorkflowRunsByRepo("hoge")
+			if err == nil && runs["CI"].GetConclusion() != "success" {
+				t.Errorf("workflow run was not added: %v", runs)
+			}
+			return err
+		}},
+	}
+	for _, c := range cases {
+		testRecorder := httptest.NewRecorder()
+		testHandler.ServeHTTP(testRecorder, newWebhookRequest(t, c.eventType, c.fixture, testSecret))
+
+		if status := testRecorder.Code; status != http.StatusOK {
+			t.Errorf("%s: handler returned wrong status code: got %v want %v",
+				c.eventType, status, http.StatusOK)
+		}
+		if err := c.check(); err != nil {
+			t.Errorf("%s: %+v", c.eventType, err)
+		}
+	}
+}
+
+func TestWebhookHandlerInvalidSignature(t *testing.T) {
+	testHandler := NewWebhookHandler(testSecret)
+	testRecorder := httptest.NewRecorder()
+
+	testHandler.ServeHTTP(testRecorder, newWebhookRequest(t, "pull_request", "pull_request.json", "wrong"))
+
+	if status := testRecorder.Code; status != http.StatusUnauthorized {
+		t.Errorf("handler returned wrong status code: got %v want %v",
+			status, http.StatusUnauthorized)
+	}
+}
+
+func TestWebhookHandlerMethodNotAllowed(t *testing.T) {
+	testHandler := NewWebhookHandler(testSecret)
+	testRecorder := httptest.NewRecorder()
+
+	req, err := http.NewRequest("GET", "/webhook", nil)
+	if err != nil {
+		t.Fatalf("%+v\n", err)
+	}
+	testHandler.ServeHTTP(testRecorder, req)
+
+	if status := testRecorder.Code; status != http.StatusMethodNotAllowed {
+		t.Errorf("handler returned wrong status code: got %v want %v",
+			status, http.StatusMethodNotAllowed)
+	}
+}
@@ -27,11 +27,6 @@ func main() {
 		log.Fatalf("failed to initialize github client: %v", err)
 	}
 
-	// background worker
-	w := exporter.NewWorker()
-	d := exporter.NewDispatcher(w)
-	d.Start(ctx) // start background job queue and worker
-
 	// setting exporter and job initialization
 	orgs := strings.Split(config.GitHubConfig.Orgs, ",")
 	jobs := make([]*exporter.Job, len(orgs))
@@ -40,35 +35,62 @@ func main() {
 		jobs[i] = exporter.NewJob(client, org)
 		collectors[i] = exporter.NewGitHubCollector(org)
 	}
-	go func(ctx context.Context) {
-		// initialized
-		for _, job := range jobs {
-			d.Add(job)
+
+	// background worker
+	d := exporter.NewDispatcher(exporter.NewWorker())
+	if config.RemoteWriteConfig.URL != "" {
+		// push mode for environments where Prometheus cannot scrape the exporter
+		d.OnIdle(exporter.NewRemoteWriter(exporter.NewRegistry(collectors)).PushAfterJobs)
+	}
+	d.Start(ctx) // start background job queue and worker
+	scheduler := exporter.NewScheduler(d, jobs, time.Duration(config.GitHubConfig.Interval)*time.Minute)
+	scheduler.Start(ctx)
+
+	// OpenTelemetry exporters alongside /metrics
+	shutdownOTel, err := exporter.SetupOpenTelemetry(ctx, exporter.NewRegistry(collectors))
+	if err != nil {
+		log.Fatalf("failed to initialize OpenTelemetry: %v", err)
+	}
+	defer func() {
+		if err := shutdownOTel(context.Background()); err != nil {
+			log.Warnf("Failed to shutdown OpenTelemetry: %v", err)
 		}
-		ticker := time.NewTicker(
-			time.Duration(config.GitHubConfig.Interval) * time.Minute,
-		)
-		defer ticker.Stop()
-		for {
-			select {
-			case <-ctx.Done():
-				log.Warnf("stop loop api call")
-				return
-			case <-ticker.C:
-				for _, job := range jobs {
-					d.Add(job)
-				}
-			}
+	}()
+
+	// TLS and basic auth of exporter-toolkit web config file
+	var webConfig *handlers.WebConfigFile
+	if config.WebConfig.ConfigFile != "" {
+		webConfig, err = handlers.NewWebConfigFile(config.WebConfig.ConfigFile)
+		if err != nil {
+			log.Fatalf("failed to load web config: %v", err)
 		}
-	}(ctx)
+	}
 
 	// setting http server
 	routes := handlers.NewRoutes()
-	routes.LivenessHandler = handlers.NewLivenessHandler()
-	routes.ReadinessHandler = handlers.NewReadinessHandler()
+	routes.Authenticator = handlers.NewAuthenticator(webConfig, config.WebConfig.BearerToken)
+	routes.LivenessHandler = handlers.NewLivenessHandler(scheduler, config.ServerConfig.LivenessMaxIntervals)
+	routes.ReadinessHandler = handlers.NewReadinessHandler(scheduler)
 	routes.NotFoundHandler = handlers.NewNotFoundHandler()
 	// custom metrics handler
 	routes.MetricsHandler = handlers.NewMetricsHandler(collectors)
+	// read-only raw data of the cache
+	routes.APIHandler = handlers.NewAPIHandler(collectors)
+	routes.StatusHandler = handlers.NewStatusHandler(jobs, d)
+	var adminServer *http.Server
+	if config.ServerConfig.AdminPort != 0 {
+		// admin endpoints are not exposed with the others
+		adminServer = &http.Server{
+			Addr:    fmt.Sprintf(":%d", config.ServerConfig.AdminPort),
+			Handler: handlers.ApplyMiddleware(handlers.NewAdminHandler(scheduler, config.ServerConfig.AdminToken)),
+		}
+	} else if config.ServerConfig.AdminToken != "" {
+		routes.AdminHandler = handlers.NewAdminHandler(scheduler, config.ServerConfig.AdminToken)
+	}
+	if config.GitHubConfig.WebhookSecret != "" {
+		// near-real-time updates. polling still reconciles the cache.
+		routes.WebhookHandler = handlers.NewWebhookHandler(config.GitHubConfig.WebhookSecret)
+	}
 
 	handler := routes.Handler()
 
@@ -79,11 +101,20 @@ func main() {
 	// run server
 	go func() {
 		log.Infof("Listen at %s port\n", server.Addr)
-		if err := server.ListenAndServe(); err != nil {
+		if err := handlers.ListenAndServe(server, webConfig); err != nil {
 			log.Fatalf("%+v\n", err)
 		}
 	}()
 
+	if adminServer != nil {
+		go func() {
+			log.Infof("Listen admin at %s port\n", adminServer.Addr)
+			if err := handlers.ListenAndServe(adminServer, webConfig); err != nil {
+				log.Fatalf("%+v\n", err)
+			}
+		}()
+	}
+
 	// graceful shutdown
 	sigint := make(chan os.Signal, 1)
 	signal.Notify(sigint, syscall.SIGTERM, os.Interrupt)
@@ -92,5 +123,10 @@ func main() {
 		// Error from closing listeners, or context timeout:
 		log.Warnf("Failed to gracefully shutdown: %v", err)
 	}
+	if adminServer != nil {
+		if err := adminServer.Shutdown(ctx); err != nil {
+			log.Warnf("Failed to gracefully shutdown admin server: %v", err)
+		}
+	}
 	log.Info("Server shutdown")
 }